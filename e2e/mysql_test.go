@@ -1 +1,166 @@
 package e2e
+
+import (
+	goctx "context"
+	"testing"
+
+	framework "github.com/operator-framework/operator-sdk/pkg/test"
+	"github.com/woohhan/sample-mysql-operator/pkg/apis"
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestMySQL(t *testing.T) {
+	if err := framework.AddToFrameworkScheme(apis.AddToScheme, &mysqlv1alpha1.MySQLList{}); err != nil {
+		t.Fatal(err)
+	}
+	ctx := framework.NewContext(t)
+	defer func() {
+		ctx.Cleanup()
+	}()
+	if err := deployResources(t, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitForOperator(t, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := testScale(t, ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := testVersion80(t, ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testScale 는 MySQL 의 replicas 가 스테이트풀셋의 레플리카 수에 반영되는지 확인한다
+func testScale(t *testing.T, ctx *framework.Context) error {
+	namespace, err := ctx.GetWatchNamespace()
+	if err != nil {
+		return err
+	}
+	replicas := int32(1)
+	mysql := &mysqlv1alpha1.MySQL{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "scale",
+			Namespace: namespace,
+		},
+		Spec: mysqlv1alpha1.MySQLSpec{
+			Replicas: &replicas,
+		},
+	}
+	if err := framework.Global.Client.Create(goctx.TODO(), mysql, &cleanupOptions); err != nil {
+		return err
+	}
+	if err := waitForStatefulSetReplicas(t, namespace, mysql.Name, replicas); err != nil {
+		return err
+	}
+
+	t.Log("Scaling up mysql...")
+	if err := framework.Global.Client.Get(goctx.TODO(), types.NamespacedName{Namespace: namespace, Name: mysql.Name}, mysql); err != nil {
+		return err
+	}
+	replicas = 2
+	mysql.Spec.Replicas = &replicas
+	if err := framework.Global.Client.Update(goctx.TODO(), mysql); err != nil {
+		return err
+	}
+	if err := waitForStatefulSetReplicas(t, namespace, mysql.Name, replicas); err != nil {
+		return err
+	}
+	return waitForMySQLReady(t, namespace, mysql.Name)
+}
+
+// testVersion80 은 MySQL 8.0 클러스터의 레플리카가 8.0 의 백업 도구 이미지로 데이터를 복제(clone)하고,
+// 기본 인증 방식(caching_sha2_password)을 사용하는 복제 계정으로 프라이머리를 복제하는지 확인한다
+func testVersion80(t *testing.T, ctx *framework.Context) error {
+	namespace, err := ctx.GetWatchNamespace()
+	if err != nil {
+		return err
+	}
+	replicas := int32(2)
+	mysql := &mysqlv1alpha1.MySQL{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "version80",
+			Namespace: namespace,
+		},
+		Spec: mysqlv1alpha1.MySQLSpec{
+			Version:  "8.0",
+			Replicas: &replicas,
+		},
+	}
+	if err := framework.Global.Client.Create(goctx.TODO(), mysql, &cleanupOptions); err != nil {
+		return err
+	}
+	if err := waitForStatefulSetReplicas(t, namespace, mysql.Name, replicas); err != nil {
+		return err
+	}
+	if err := waitForMySQLReady(t, namespace, mysql.Name); err != nil {
+		return err
+	}
+	return waitForReplicating(t, namespace, mysql.Name)
+}
+
+// waitForReplicating 은 MySQL 의 모든 레플리카의 IO 스레드와 SQL 스레드가 실행 중일 때까지 기다린다
+func waitForReplicating(t *testing.T, namespace, name string) error {
+	return wait.Poll(retryInterval, timeout, func() (bool, error) {
+		mysql := &mysqlv1alpha1.MySQL{}
+		if err := framework.Global.Client.Get(goctx.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, mysql); err != nil {
+			return false, err
+		}
+		replicating := 0
+		for _, member := range mysql.Status.Members {
+			if member.Role != "replica" {
+				continue
+			}
+			if member.IOThread != "Yes" || member.SQLThread != "Yes" {
+				t.Logf("Waiting for %s to replicate (io: %s, sql: %s, error: %s)",
+					member.Name, member.IOThread, member.SQLThread, member.LastError)
+				return false, nil
+			}
+			replicating++
+		}
+		if replicating != int(mysql.Spec.GetReplicas())-1 {
+			t.Logf("Waiting for replicas of %s mysql (%d/%d)", name, replicating, mysql.Spec.GetReplicas()-1)
+			return false, nil
+		}
+		return true, nil
+	})
+}
+
+// waitForStatefulSetReplicas 는 스테이트풀셋의 준비된 레플리카 수가 replicas 가 될 때까지 기다린다
+func waitForStatefulSetReplicas(t *testing.T, namespace, name string, replicas int32) error {
+	return wait.Poll(retryInterval, timeout, func() (bool, error) {
+		statefulSet := &appsv1.StatefulSet{}
+		if err := framework.Global.Client.Get(goctx.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, statefulSet); err != nil {
+			if errors.IsNotFound(err) {
+				t.Logf("Waiting for availability of %s stateful set", name)
+				return false, nil
+			}
+			return false, err
+		}
+		if statefulSet.Spec.Replicas == nil || *statefulSet.Spec.Replicas != replicas || statefulSet.Status.ReadyReplicas != replicas {
+			t.Logf("Waiting for %s stateful set (%d/%d)", name, statefulSet.Status.ReadyReplicas, replicas)
+			return false, nil
+		}
+		return true, nil
+	})
+}
+
+// waitForMySQLReady 는 MySQL 의 Ready 컨디션이 True 가 될 때까지 기다린다
+func waitForMySQLReady(t *testing.T, namespace, name string) error {
+	return wait.Poll(retryInterval, timeout, func() (bool, error) {
+		mysql := &mysqlv1alpha1.MySQL{}
+		if err := framework.Global.Client.Get(goctx.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, mysql); err != nil {
+			return false, err
+		}
+		if !mysql.Status.Conditions.IsTrueFor(mysqlv1alpha1.ConditionReady) {
+			t.Logf("Waiting for %s mysql to be ready (phase: %s)", name, mysql.Status.Phase)
+			return false, nil
+		}
+		return true, nil
+	})
+}
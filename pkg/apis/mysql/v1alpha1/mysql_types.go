@@ -1,6 +1,9 @@
 package v1alpha1
 
 import (
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -12,6 +15,378 @@ type MySQLSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
 	// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+
+	// Replicas 는 MySQL 멤버(파드)의 수이다. 첫 번째 멤버는 프라이머리, 나머지는 레플리카가 된다.
+	// 지정하지 않으면 DefaultReplicas 를 사용한다
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Config 는 모든 멤버에 공통으로 적용할 my.cnf 설정이다
+	// +optional
+	Config MySQLConfig `json:"config,omitempty"`
+
+	// PrimaryConfig 는 프라이머리에만 적용할 my.cnf 설정이다. Config 와 같은 옵션이 있으면 이 값을 사용한다
+	// +optional
+	PrimaryConfig MySQLConfig `json:"primaryConfig,omitempty"`
+
+	// ReplicaConfig 는 레플리카에만 적용할 my.cnf 설정이다. Config 와 같은 옵션이 있으면 이 값을 사용한다
+	// +optional
+	ReplicaConfig MySQLConfig `json:"replicaConfig,omitempty"`
+
+	// Version 은 MySQL 서버의 버전이다. 버전에 따라 mysqld 와 백업 도구의 이미지가 정해진다
+	// 지정하지 않으면 DefaultVersion 을 사용한다
+	// +kubebuilder:validation:Enum="5.7";"8.0"
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Image 는 mysqld 컨테이너의 이미지이다. 지정하면 Version 에 맞는 기본 이미지 대신 사용한다
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// ImagePullPolicy 는 모든 컨테이너의 이미지 풀 정책이다
+	// +kubebuilder:validation:Enum=Always;Never;IfNotPresent
+	// +optional
+	ImagePullPolicy corev1.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// BackupSidecar 는 멤버 사이에 데이터를 복제하는 xtrabackup 사이드카의 설정이다
+	// +optional
+	BackupSidecar *BackupSidecarSpec `json:"backupSidecar,omitempty"`
+
+	// Resources 는 mysql 컨테이너의 리소스 요청과 제한이다. 지정하지 않으면 CPU 500m, 메모리 1Gi 를 요청한다
+	// 값을 바꾸면 멤버가 하나씩 차례로 다시 시작된다
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Storage 는 각 멤버의 데이터를 저장하는 볼륨 클레임의 설정이다
+	// +optional
+	Storage *StorageSpec `json:"storage,omitempty"`
+
+	// RootPasswordSecretRef 는 root 계정의 비밀번호를 가진 시크릿의 키이다
+	// 지정하지 않으면 오퍼레이터가 무작위 비밀번호를 가진 시크릿을 만든다
+	// +optional
+	RootPasswordSecretRef *corev1.SecretKeySelector `json:"rootPasswordSecretRef,omitempty"`
+
+	// PodTemplate 는 멤버 파드를 어느 노드에 배치할지에 대한 설정이다
+	// +optional
+	PodTemplate *PodTemplate `json:"podTemplate,omitempty"`
+
+	// AntiAffinity 는 멤버들이 같은 노드에 배치되지 않도록 하는 정책이다. 지정하지 않으면 preferred 이다
+	// none 이 아니면 멤버들을 가능한 한 여러 존(zone)에 분산시킨다
+	// +kubebuilder:validation:Enum=none;preferred;required
+	// +optional
+	AntiAffinity AntiAffinityPolicy `json:"antiAffinity,omitempty"`
+
+	// DeletionPolicy 는 MySQL 객체를 삭제할 때 멤버의 데이터 볼륨 클레임을 어떻게 처리할지 정한다
+	// 지정하지 않으면 Retain 이다. Retain 이면 오퍼레이터가 만든 비밀번호 시크릿도 남겨두고, 같은 이름의 MySQL 객체를 다시 만들면 이어받는다
+	// +kubebuilder:validation:Enum=Retain;Delete;Snapshot
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// Failover 는 프라이머리에 장애가 났을 때 레플리카를 프라이머리로 승격하는 설정이다
+	// +optional
+	Failover *FailoverSpec `json:"failover,omitempty"`
+
+	// Replication 은 멤버 사이의 복제 설정이다
+	// +optional
+	Replication *ReplicationSpec `json:"replication,omitempty"`
+
+	// Topology 는 멤버 사이의 복제 구조이다. 지정하지 않으면 async 이다
+	// groupReplication 은 MySQL 8.0 에서만 사용할 수 있으며, 클러스터를 만든 뒤에는 바꿀 수 없다
+	// +kubebuilder:validation:Enum=async;groupReplication
+	// +optional
+	Topology Topology `json:"topology,omitempty"`
+
+	// Primary 는 프라이머리가 될 파드의 이름이다. 현재 프라이머리와 다르면 데이터 손실 없이 프라이머리를 바꾼다(switchover)
+	// 지정하지 않으면 오퍼레이터가 정한 프라이머리를 유지한다. 자동 페일오버로 물러난 멤버를 가리키면 페일오버 뒤에 스펙이 바뀔 때까지
+	// 되돌리지 않고 SwitchoverBlocked 컨디션으로 알린다
+	// +optional
+	Primary string `json:"primary,omitempty"`
+
+	// ReadService 는 레플리카에 읽기를 분산하는 읽기용 서비스의 설정이다
+	// +optional
+	ReadService *ReadServiceSpec `json:"readService,omitempty"`
+}
+
+// ReadServiceSpec 는 읽기용 서비스의 설정이다
+type ReadServiceSpec struct {
+	// MaxLagSeconds 는 읽기용 서비스가 고를 레플리카의 최대 복제 지연이다. 지정하지 않으면 30 이다
+	// 복제가 멈췄거나 지연이 이 값보다 큰 레플리카는 고르지 않으며, 고를 레플리카가 없으면 프라이머리를 고른다
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxLagSeconds *int32 `json:"maxLagSeconds,omitempty"`
+}
+
+// DefaultReadServiceMaxLagSeconds 는 ReadServiceSpec.MaxLagSeconds 가 지정되지 않았을 때 사용하는 값이다
+const DefaultReadServiceMaxLagSeconds = 30
+
+// GetReadServiceMaxLagSeconds 는 기본값을 반영한 읽기용 서비스의 최대 복제 지연을 리턴한다
+func (s *MySQLSpec) GetReadServiceMaxLagSeconds() int32 {
+	if s.ReadService == nil || s.ReadService.MaxLagSeconds == nil {
+		return DefaultReadServiceMaxLagSeconds
+	}
+	return *s.ReadService.MaxLagSeconds
+}
+
+// Topology 는 멤버 사이의 복제 구조이다
+type Topology string
+
+const (
+	// TopologyAsync 는 오퍼레이터가 정한 프라이머리를 레플리카가 복제하는 구조이다. 페일오버는 오퍼레이터가 처리한다
+	TopologyAsync Topology = "async"
+	// TopologyGroupReplication 은 MySQL 그룹 복제(Group Replication)의 단일 프라이머리 구조이다
+	// 프라이머리는 그룹이 합의로 선출하며, 그룹 복제는 항상 GTID 로 복제한다
+	TopologyGroupReplication Topology = "groupReplication"
+)
+
+// GetTopology 는 기본값을 반영한 복제 구조를 리턴한다
+func (s *MySQLSpec) GetTopology() Topology {
+	if s.Topology == "" {
+		return TopologyAsync
+	}
+	return s.Topology
+}
+
+// ReplicationSpec 는 멤버 사이의 복제 설정이다
+type ReplicationSpec struct {
+	// Mode 는 레플리카가 복제할 위치를 찾는 방법이다. 지정하지 않으면 binlog 이다
+	// 클러스터를 만든 뒤에는 바꿀 수 없다
+	// +kubebuilder:validation:Enum=binlog;gtid
+	// +optional
+	Mode ReplicationMode `json:"mode,omitempty"`
+
+	// SemiSync 는 반동기(semi-synchronous) 복제 설정이다. 지정하지 않으면 비동기로 복제한다
+	// +optional
+	SemiSync *SemiSyncSpec `json:"semiSync,omitempty"`
+}
+
+// SemiSyncSpec 는 반동기 복제의 설정이다
+type SemiSyncSpec struct {
+	// Enabled 가 true 이면 프라이머리는 레플리카가 트랜잭션을 받았다고 응답한 뒤에 커밋을 완료한다
+	Enabled bool `json:"enabled"`
+
+	// WaitForReplicaCount 는 커밋을 완료하기 전에 응답을 기다리는 레플리카의 수이다. 지정하지 않으면 1 이다
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	WaitForReplicaCount *int32 `json:"waitForReplicaCount,omitempty"`
+
+	// TimeoutSeconds 는 레플리카의 응답을 기다리는 최대 시간이다. 시간 안에 응답이 없으면 비동기 복제로 바뀌고,
+	// 레플리카가 따라잡으면 다시 반동기 복제로 돌아온다. 지정하지 않으면 10 이다
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+}
+
+// ReplicationMode 는 레플리카가 복제할 위치를 찾는 방법이다
+type ReplicationMode string
+
+const (
+	// ReplicationModeBinlog 는 프라이머리의 바이너리 로그 파일 이름과 위치로 복제한다
+	ReplicationModeBinlog ReplicationMode = "binlog"
+	// ReplicationModeGTID 는 GTID 로 복제한다. 레플리카는 프라이머리가 바뀌어도 위치와 관계없이 이어서 복제할 수 있다
+	ReplicationModeGTID ReplicationMode = "gtid"
+)
+
+// GetReplicationMode 는 기본값을 반영한 복제 방법을 리턴한다. 그룹 복제의 기본값은 gtid 이다
+func (s *MySQLSpec) GetReplicationMode() ReplicationMode {
+	if s.Replication == nil || s.Replication.Mode == "" {
+		if s.GetTopology() == TopologyGroupReplication {
+			return ReplicationModeGTID
+		}
+		return ReplicationModeBinlog
+	}
+	return s.Replication.Mode
+}
+
+// SemiSyncSpec 의 기본값
+const (
+	DefaultSemiSyncWaitForReplicaCount = 1
+	DefaultSemiSyncTimeoutSeconds      = 10
+)
+
+// IsSemiSyncEnabled 는 반동기 복제를 사용하는지 리턴한다
+func (s *MySQLSpec) IsSemiSyncEnabled() bool {
+	return s.Replication != nil && s.Replication.SemiSync != nil && s.Replication.SemiSync.Enabled
+}
+
+// GetSemiSyncWaitForReplicaCount 는 기본값을 반영한, 커밋 전에 응답을 기다리는 레플리카의 수를 리턴한다
+func (s *MySQLSpec) GetSemiSyncWaitForReplicaCount() int32 {
+	if !s.IsSemiSyncEnabled() || s.Replication.SemiSync.WaitForReplicaCount == nil {
+		return DefaultSemiSyncWaitForReplicaCount
+	}
+	return *s.Replication.SemiSync.WaitForReplicaCount
+}
+
+// GetSemiSyncTimeoutSeconds 는 기본값을 반영한 반동기 복제의 타임아웃을 리턴한다
+func (s *MySQLSpec) GetSemiSyncTimeoutSeconds() int32 {
+	if !s.IsSemiSyncEnabled() || s.Replication.SemiSync.TimeoutSeconds == nil {
+		return DefaultSemiSyncTimeoutSeconds
+	}
+	return *s.Replication.SemiSync.TimeoutSeconds
+}
+
+// FailoverSpec 는 자동 페일오버의 설정이다
+type FailoverSpec struct {
+	// Enabled 가 false 이면 프라이머리에 장애가 나도 레플리카를 승격하지 않는다. 지정하지 않으면 true 이다
+	// +optional
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// TimeoutSeconds 는 프라이머리가 이 시간 동안 준비되지 않으면 장애로 판단한다. 지정하지 않으면 60 이다
+	// +kubebuilder:validation:Minimum=10
+	// +optional
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+}
+
+// DefaultFailoverTimeoutSeconds 는 FailoverSpec.TimeoutSeconds 가 지정되지 않았을 때 사용하는 값이다
+const DefaultFailoverTimeoutSeconds = 60
+
+// IsFailoverEnabled 는 자동 페일오버를 사용하는지 리턴한다
+func (s *MySQLSpec) IsFailoverEnabled() bool {
+	return s.Failover == nil || s.Failover.Enabled == nil || *s.Failover.Enabled
+}
+
+// GetFailoverTimeoutSeconds 는 기본값을 반영한 페일오버 타임아웃을 리턴한다
+func (s *MySQLSpec) GetFailoverTimeoutSeconds() int32 {
+	if s.Failover == nil || s.Failover.TimeoutSeconds == nil {
+		return DefaultFailoverTimeoutSeconds
+	}
+	return *s.Failover.TimeoutSeconds
+}
+
+// DeletionPolicy 는 MySQL 객체를 삭제할 때 데이터 볼륨 클레임을 처리하는 정책이다
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyRetain 은 데이터 볼륨 클레임과 오퍼레이터가 만든 비밀번호 시크릿을 남겨둔다
+	// 남겨둔 데이터 디렉터리의 계정은 시크릿의 비밀번호를 가지므로 함께 남겨야 다시 만든 클러스터가 접속할 수 있다
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicyDelete 는 데이터 볼륨 클레임을 삭제한다
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicySnapshot 은 각 데이터 볼륨의 스냅샷을 만든 뒤 데이터 볼륨 클레임을 삭제한다
+	// 스냅샷은 MySQL 객체가 삭제된 뒤에도 남는다
+	DeletionPolicySnapshot DeletionPolicy = "Snapshot"
+)
+
+// GetDeletionPolicy 는 기본값을 반영한 삭제 정책을 리턴한다
+func (s *MySQLSpec) GetDeletionPolicy() DeletionPolicy {
+	if s.DeletionPolicy == "" {
+		return DeletionPolicyRetain
+	}
+	return s.DeletionPolicy
+}
+
+// AntiAffinityPolicy 는 멤버 파드 사이의 안티 어피니티 정책이다
+type AntiAffinityPolicy string
+
+const (
+	// AntiAffinityNone 은 멤버들의 배치에 제약을 두지 않는다
+	AntiAffinityNone AntiAffinityPolicy = "none"
+	// AntiAffinityPreferred 는 가능하면 멤버들을 서로 다른 노드에 배치한다
+	AntiAffinityPreferred AntiAffinityPolicy = "preferred"
+	// AntiAffinityRequired 는 멤버들을 반드시 서로 다른 노드에 배치한다. 노드가 멤버의 수보다 적으면 일부 멤버는 배치되지 않는다
+	AntiAffinityRequired AntiAffinityPolicy = "required"
+)
+
+// GetAntiAffinity 는 기본값을 반영한 안티 어피니티 정책을 리턴한다
+func (s *MySQLSpec) GetAntiAffinity() AntiAffinityPolicy {
+	if s.AntiAffinity == "" {
+		return AntiAffinityPreferred
+	}
+	return s.AntiAffinity
+}
+
+// PodTemplate 는 멤버 파드의 스케줄링 설정이다. 각 필드는 파드 스펙의 같은 이름의 필드에 그대로 들어간다
+type PodTemplate struct {
+	// NodeSelector 는 파드를 배치할 노드의 레이블이다
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Affinity 는 파드의 어피니티 규칙이다
+	// +optional
+	Affinity *corev1.Affinity `json:"affinity,omitempty"`
+
+	// Tolerations 는 파드가 허용할 노드의 테인트이다
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+
+	// PriorityClassName 은 파드의 우선순위 클래스 이름이다
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+
+	// TopologySpreadConstraints 는 파드를 토폴로지 도메인에 분산시키는 규칙이다
+	// +optional
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+}
+
+// StorageSpec 는 데이터 볼륨 클레임의 설정이다. 지정하지 않은 필드는 기본값을 사용한다
+type StorageSpec struct {
+	// Size 는 데이터 볼륨의 크기이다. 지정하지 않으면 2Gi 이다
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+
+	// StorageClassName 은 데이터 볼륨의 스토리지 클래스이다. 지정하지 않으면 클러스터의 기본 스토리지 클래스를 사용한다
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// AccessModes 는 데이터 볼륨의 접근 모드이다. 지정하지 않으면 ReadWriteOnce 이다
+	// +optional
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+
+	// Labels 는 데이터 볼륨 클레임에 붙일 레이블이다
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations 는 데이터 볼륨 클레임에 붙일 어노테이션이다
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// DeleteOnScaleDown 이 true 이면 멤버의 수가 줄어들 때 제거된 멤버의 데이터 볼륨 클레임을 삭제한다
+	// 다시 멤버의 수를 늘렸을 때 오래된 데이터를 재사용하지 않고 다른 멤버로부터 새로 복제하게 된다
+	// +optional
+	DeleteOnScaleDown bool `json:"deleteOnScaleDown,omitempty"`
+
+	// VolumeSnapshotClassName 은 DeletionPolicy 가 Snapshot 일 때 만드는 볼륨 스냅샷의 클래스이다
+	// 지정하지 않으면 클러스터의 기본 볼륨 스냅샷 클래스를 사용한다
+	// +optional
+	VolumeSnapshotClassName *string `json:"volumeSnapshotClassName,omitempty"`
+}
+
+// DefaultVersion 은 MySQLSpec.Version 이 지정되지 않았을 때 사용하는 MySQL 서버의 버전이다
+const DefaultVersion = "5.7"
+
+// GetVersion 은 기본값을 반영한 MySQL 서버의 버전을 리턴한다
+func (s *MySQLSpec) GetVersion() string {
+	if s.Version == "" {
+		return DefaultVersion
+	}
+	return s.Version
+}
+
+// BackupSidecarSpec 는 xtrabackup 사이드카의 설정이다
+type BackupSidecarSpec struct {
+	// Image 는 xtrabackup 사이드카와 clone-mysql 초기화 컨테이너의 이미지이다
+	// 지정하면 Version 에 맞는 기본 이미지 대신 사용한다
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Resources 는 xtrabackup 사이드카의 리소스 요청과 제한이다. 지정하지 않으면 CPU 100m, 메모리 100Mi 를 요청한다
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// MySQLConfig 는 my.cnf 파일의 내용이다. 키는 섹션 이름(예: mysqld)이고 값은 해당 섹션의 옵션이다
+// 값이 빈 문자열인 옵션은 log-bin 처럼 옵션 이름만 기록된다
+type MySQLConfig map[string]map[string]string
+
+// DefaultReplicas 는 MySQLSpec.Replicas 가 지정되지 않았을 때 사용하는 멤버의 수이다
+const DefaultReplicas int32 = 2
+
+// GetReplicas 는 기본값을 반영한 멤버의 수를 리턴한다
+func (s *MySQLSpec) GetReplicas() int32 {
+	if s.Replicas == nil {
+		return DefaultReplicas
+	}
+	return *s.Replicas
 }
 
 // MySQLStatus defines the observed state of MySQL
@@ -19,13 +394,193 @@ type MySQLStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "operator-sdk generate k8s" to regenerate code after modifying this file
 	// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+
+	// Phase 는 MySQL 클러스터의 전체적인 상태를 한 단어로 나타낸다
+	// +optional
+	Phase MySQLPhase `json:"phase,omitempty"`
+
+	// Conditions 는 MySQL 클러스터에 대한 관찰 결과이다. Ready, Progressing, ReplicationHealthy, VolumeResizing 을 가진다
+	// +optional
+	Conditions status.Conditions `json:"conditions,omitempty"`
+
+	// ReadyReplicas 는 준비(Ready) 상태인 멤버의 수이다
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// CurrentPrimary 는 현재 프라이머리인 파드의 이름이다
+	// +optional
+	CurrentPrimary string `json:"currentPrimary,omitempty"`
+
+	// PrimaryNotReadySince 는 프라이머리가 준비되지 않은 상태가 된 시각이다. 프라이머리가 준비되어 있으면 비어 있다
+	// 페일오버 타임아웃은 이 시각부터 잰다
+	// +optional
+	PrimaryNotReadySince *metav1.Time `json:"primaryNotReadySince,omitempty"`
+
+	// LastFailover 는 마지막으로 프라이머리를 바꾼 기록이다
+	// +optional
+	LastFailover *FailoverRecord `json:"lastFailover,omitempty"`
+
+	// ReplicationMode 는 클러스터를 만들 때 사용한 복제 방법이다
+	// +optional
+	ReplicationMode ReplicationMode `json:"replicationMode,omitempty"`
+
+	// Topology 는 클러스터를 만들 때 사용한 복제 구조이다
+	// +optional
+	Topology Topology `json:"topology,omitempty"`
+
+	// DataVolumeClaimTemplate 은 클러스터를 만들 때 사용한 데이터 볼륨 클레임의 설정이다
+	// +optional
+	DataVolumeClaimTemplate *DataVolumeClaimTemplateStatus `json:"dataVolumeClaimTemplate,omitempty"`
+
+	// Members 는 비동기 복제 구조에서 각 멤버의 역할과 복제 상태이다
+	// +optional
+	Members []MemberStatus `json:"members,omitempty"`
+
+	// GroupMembers 는 그룹 복제를 사용할 때 각 멤버의 그룹 안에서의 상태와 역할이다
+	// +optional
+	GroupMembers []GroupMemberStatus `json:"groupMembers,omitempty"`
+
+	// Version 은 모든 멤버에서 실행 중인 MySQL 서버의 버전이다
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// DeletionPolicy 는 MySQL 객체를 삭제하는 중에 적용하고 있는 삭제 정책이다
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// ObservedGeneration 은 오퍼레이터가 마지막으로 클러스터에 반영한 스펙의 세대(generation)이다
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+}
+
+// DataVolumeClaimTemplateStatus 는 데이터 볼륨 클레임 템플릿에서 크기 외에 바꿀 수 없는 설정이다
+type DataVolumeClaimTemplateStatus struct {
+	// StorageClassName 은 데이터 볼륨의 스토리지 클래스이다. 비어 있으면 클러스터의 기본 스토리지 클래스이다
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// AccessModes 는 데이터 볼륨의 접근 모드이다
+	// +optional
+	AccessModes []corev1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+
+	// Labels 는 데이터 볼륨 클레임에 붙인 레이블이다
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations 는 데이터 볼륨 클레임에 붙인 어노테이션이다
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// FailoverRecord 는 프라이머리를 바꾼 기록이다
+type FailoverRecord struct {
+	// Time 은 새로운 프라이머리를 승격한 시각이다
+	Time metav1.Time `json:"time"`
+
+	// OldPrimary 는 이전 프라이머리인 파드의 이름이다
+	OldPrimary string `json:"oldPrimary"`
+
+	// NewPrimary 는 승격한 파드의 이름이다
+	NewPrimary string `json:"newPrimary"`
+
+	// Reason 은 프라이머리를 바꾼 이유이다
+	Reason string `json:"reason"`
+
+	// Message 는 프라이머리를 바꾸면서 처리하지 못한 멤버 등 사람이 읽을 수 있는 자세한 내용이다
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// ObservedGeneration 은 프라이머리를 바꿀 때의 MySQL 객체의 세대이다
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
 }
 
+// MemberStatus 는 멤버의 역할과 복제 상태이다. 복제 상태는 SHOW SLAVE STATUS 로 관찰한다
+type MemberStatus struct {
+	// Name 은 멤버 파드의 이름이다
+	Name string `json:"name"`
+
+	// Role 은 멤버의 역할로, primary 또는 replica 이다
+	Role string `json:"role"`
+
+	// IOThread 는 프라이머리로부터 바이너리 로그를 받는 IO 스레드의 상태로, Yes, No 또는 Connecting 이다
+	// 준비되지 않았거나 복제하지 않는 멤버는 비어 있다
+	// +optional
+	IOThread string `json:"ioThread,omitempty"`
+
+	// SQLThread 는 받은 바이너리 로그를 적용하는 SQL 스레드의 상태로, Yes 또는 No 이다
+	// +optional
+	SQLThread string `json:"sqlThread,omitempty"`
+
+	// LastError 는 복제를 멈추게 한 마지막 에러이다
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+
+	// SecondsBehindPrimary 는 레플리카의 복제 지연이다. 복제가 멈춰서 알 수 없으면 비어 있다
+	// +optional
+	SecondsBehindPrimary *int64 `json:"secondsBehindPrimary,omitempty"`
+}
+
+// GroupMemberStatus 는 그룹 복제에 참여하는 멤버의 상태이다
+type GroupMemberStatus struct {
+	// Name 은 멤버 파드의 이름이다
+	Name string `json:"name"`
+
+	// State 는 performance_schema.replication_group_members 의 MEMBER_STATE 이다
+	// 그룹에 참여하지 않았거나 접속할 수 없는 멤버는 OFFLINE 이다
+	State string `json:"state"`
+
+	// Role 은 그룹 안에서의 역할로, PRIMARY 또는 SECONDARY 이다
+	// +optional
+	Role string `json:"role,omitempty"`
+}
+
+// MySQLPhase 는 MySQL 클러스터의 전체적인 상태이다
+type MySQLPhase string
+
+const (
+	// MySQLPhaseCreating 는 클러스터를 만들고 있어서 아직 모든 멤버가 준비되지 않은 상태이다
+	MySQLPhaseCreating MySQLPhase = "Creating"
+	// MySQLPhaseReady 는 모든 멤버가 준비된 상태이다
+	MySQLPhaseReady MySQLPhase = "Ready"
+	// MySQLPhaseDegraded 는 한 번 준비되었던 클러스터의 일부 멤버가 준비되지 않은 상태이다
+	MySQLPhaseDegraded MySQLPhase = "Degraded"
+	// MySQLPhaseFailed 는 오퍼레이터가 스펙을 클러스터에 반영하지 못한 상태이다
+	MySQLPhaseFailed MySQLPhase = "Failed"
+	// MySQLPhaseTerminating 은 MySQL 객체를 삭제하면서 삭제 정책을 적용하고 있는 상태이다
+	MySQLPhaseTerminating MySQLPhase = "Terminating"
+)
+
+// MySQLStatus.Conditions 가 가지는 컨디션의 종류
+const (
+	// ConditionReady 는 모든 멤버가 준비되어 클러스터를 사용할 수 있는지 나타낸다
+	ConditionReady status.ConditionType = "Ready"
+	// ConditionProgressing 은 스펙의 변경을 클러스터에 반영하는 중인지 나타낸다
+	ConditionProgressing status.ConditionType = "Progressing"
+	// ConditionReplicationHealthy 는 레플리카가 프라이머리를 정상적으로 복제하고 있는지 나타낸다
+	ConditionReplicationHealthy status.ConditionType = "ReplicationHealthy"
+	// ConditionVolumeResizing 은 데이터 볼륨을 스펙의 크기로 늘리는 중인지 나타낸다
+	ConditionVolumeResizing status.ConditionType = "VolumeResizing"
+	// ConditionReplicationBroken 은 다시 시작해도 해결되지 않는 에러로 복제가 멈춘 레플리카가 있는지 나타낸다
+	ConditionReplicationBroken status.ConditionType = "ReplicationBroken"
+	// ConditionSemiSync 는 반동기 복제를 사용할 때 프라이머리가 레플리카의 응답을 기다리고 있는지, 비동기로 바뀌었는지 나타낸다
+	ConditionSemiSync status.ConditionType = "SemiSynchronous"
+	// ConditionSwitchoverBlocked 는 스펙에 지정한 프라이머리로 데이터 손실 없이 바꿀 수 없어서 스위치오버를 미루고 있는지 나타낸다
+	ConditionSwitchoverBlocked status.ConditionType = "SwitchoverBlocked"
+	// ConditionTerminating 은 삭제 정책을 적용하는 중인지 나타낸다
+	ConditionTerminating status.ConditionType = "Terminating"
+)
+
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
 
 // MySQL is the Schema for the mysqls API
 // +kubebuilder:subresource:status
 // +kubebuilder:resource:path=mysqls,scope=Namespaced
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Ready",type="integer",JSONPath=".status.readyReplicas"
+// +kubebuilder:printcolumn:name="Version",type="string",JSONPath=".status.version"
+// +kubebuilder:printcolumn:name="Primary",type="string",JSONPath=".status.currentPrimary"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 type MySQL struct {
 	metav1.TypeMeta   `json:",inline"`
 	metav1.ObjectMeta `json:"metadata,omitempty"`
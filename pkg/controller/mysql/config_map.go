@@ -0,0 +1,203 @@
+package mysql
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// primaryConfigKey 와 replicaConfigKey 는 컨피그맵에서 각각 프라이머리와 레플리카의 설정 파일 이름이다
+	// init-mysql 초기화 컨테이너가 멤버의 역할에 따라 둘 중 하나를 /etc/mysql/conf.d 로 복사한다
+	primaryConfigKey = "master.cnf"
+	replicaConfigKey = "slave.cnf"
+	// primaryKey 는 컨피그맵에서 현재 프라이머리인 파드의 이름을 가진 키이다
+	// 초기화 컨테이너와 xtrabackup 사이드카는 이 값으로 자신의 역할과 복제할 프라이머리를 정한다
+	primaryKey = "primary"
+)
+
+// defaultPrimaryConfig 와 defaultReplicaConfig 는 사용자의 설정보다 먼저 적용되는 기본 설정이다
+// 프라이머리는 레플리카가 복제할 수 있도록 바이너리 로그를 남기고, 레플리카는 복제 외의 쓰기를 막는다
+// 레플리카도 페일오버 때 프라이머리로 승격될 수 있도록 바이너리 로그를 남긴다
+var (
+	defaultPrimaryConfig = mysqlv1alpha1.MySQLConfig{
+		"mysqld": {
+			"log-bin": "",
+		},
+	}
+	defaultReplicaConfig = mysqlv1alpha1.MySQLConfig{
+		"mysqld": {
+			"log-bin":         "",
+			"super-read-only": "",
+		},
+	}
+)
+
+// gtidConfig 는 GTID 로 복제할 때 모든 멤버에 적용하는 설정이다
+// 레플리카가 프라이머리로 승격된 뒤에도 다른 레플리카에 빠진 트랜잭션을 보낼 수 있도록 복제한 트랜잭션도 바이너리 로그에 남긴다
+var gtidConfig = mysqlv1alpha1.MySQLConfig{
+	"mysqld": {
+		"enforce-gtid-consistency": "ON",
+		"gtid-mode":                "ON",
+		"log-slave-updates":        "",
+	},
+}
+
+// getReplicationConfig 는 복제 방법에 따라 모든 멤버에 적용하는 설정을 리턴한다
+func getReplicationConfig(mysql *mysqlv1alpha1.MySQL) mysqlv1alpha1.MySQLConfig {
+	if mysql.Spec.GetReplicationMode() == mysqlv1alpha1.ReplicationModeGTID {
+		return gtidConfig
+	}
+	return nil
+}
+
+// syncConfigMap 는 mysql 설정을 담은 컨피그맵이 없는 경우 생성하고, 내용이 스펙과 다르면 갱신한다
+func (r *ReconcileMySQL) syncConfigMap(mysql *mysqlv1alpha1.MySQL) error {
+	klog.Infof("[%s] syncConfigMap", mysql.Name)
+	// 클러스터로부터 컨피그맵을 가져온다
+	configMap := &corev1.ConfigMap{}
+	if err := r.client.Get(context.TODO(), getConfigMapName(mysql), configMap); err != nil {
+		// Not Found 에러가 아닌 경우는 가져오는데 실패한 것이므로 에러를 바로 리턴한다
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		// 컨피그맵이 없으므로 생성한다
+		klog.Infof("[%s] Could not find mysql config map. Create a new one", mysql.Name)
+		return r.createConfigMap(mysql)
+	}
+	// 설정 내용이 스펙과 다르면 갱신한다. 파드는 스테이트풀셋의 설정 해시가 바뀌면서 다시 시작된다
+	desired, err := newConfigMap(mysql, r.scheme)
+	if err != nil {
+		return err
+	}
+	if !updateConfigMap(configMap, desired) {
+		return nil
+	}
+	klog.Infof("[%s] Update mysql config map", mysql.Name)
+	return r.client.Update(context.TODO(), configMap)
+}
+
+// getConfigMapName 는 mysql 설정을 담은 컨피그맵의 이름과 네임스페이스를 리턴한다
+func getConfigMapName(mysql *mysqlv1alpha1.MySQL) types.NamespacedName {
+	return types.NamespacedName{Namespace: mysql.Namespace, Name: mysql.Name + "-config"}
+}
+
+// createConfigMap 는 새로운 컨피그맵을 생성한다. 이미 컨피그맵이 존재하는 경우 성공한다
+func (r *ReconcileMySQL) createConfigMap(mysql *mysqlv1alpha1.MySQL) error {
+	configMap, err := newConfigMap(mysql, r.scheme)
+	if err != nil {
+		return err
+	}
+	if err := r.client.Create(context.TODO(), configMap); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// newConfigMap 는 컨피그맵을 위한 객체를 생성한다. 객체는 mysql 객체를 오너로 가진다
+// 프라이머리의 이름은 설정 해시에 포함하지 않으므로 프라이머리가 바뀌어도 멤버가 다시 시작되지 않는다
+func newConfigMap(mysql *mysqlv1alpha1.MySQL, scheme *runtime.Scheme) (*corev1.ConfigMap, error) {
+	data := newConfigData(mysql)
+	data[primaryKey] = getPrimaryPodName(mysql)
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        getConfigMapName(mysql).Name,
+			Namespace:   getConfigMapName(mysql).Namespace,
+			Labels:      labelsForMySQL(mysql, componentConfig),
+			Annotations: annotationsForMySQL(mysql),
+		},
+		Data: data,
+	}
+	if err := controllerutil.SetControllerReference(mysql, configMap, scheme); err != nil {
+		return nil, err
+	}
+	return configMap, nil
+}
+
+// updateConfigMap 는 live 컨피그맵을 desired 컨피그맵에 맞추고 변경이 있었는지 리턴한다
+func updateConfigMap(live, desired *corev1.ConfigMap) bool {
+	changed := mergeMetadata(&live.ObjectMeta, &desired.ObjectMeta)
+	if !equality.Semantic.DeepEqual(desired.Data, live.Data) {
+		live.Data = desired.Data
+		changed = true
+	}
+	return changed
+}
+
+// newConfigData 는 기본 설정, 복제 설정, 공통 설정, 역할별 설정 순서로 덮어써서 프라이머리와 레플리카의 설정 파일을 만든다
+func newConfigData(mysql *mysqlv1alpha1.MySQL) map[string]string {
+	replicationConfig := getReplicationConfig(mysql)
+	groupConfig := getGroupReplicationConfig(mysql)
+	return map[string]string{
+		primaryConfigKey: renderMyCnf("# Apply this config only on the master.",
+			defaultPrimaryConfig, replicationConfig, groupConfig, getSemiSyncConfig(mysql, true),
+			mysql.Spec.Config, mysql.Spec.PrimaryConfig),
+		replicaConfigKey: renderMyCnf("# Apply this config only on slaves.",
+			defaultReplicaConfig, replicationConfig, groupConfig, getSemiSyncConfig(mysql, false),
+			mysql.Spec.Config, mysql.Spec.ReplicaConfig),
+	}
+}
+
+// getConfigHash 는 설정 파일 내용의 해시를 리턴한다. 스테이트풀셋의 파드 템플릿에 기록해서 설정이 바뀌면 파드를 다시 시작하게 한다
+func getConfigHash(mysql *mysqlv1alpha1.MySQL) string {
+	data := newConfigData(mysql)
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(data[primaryConfigKey]+data[replicaConfigKey])))
+}
+
+// renderMyCnf 는 설정들을 앞에서부터 차례로 덮어쓴 뒤 my.cnf 형식의 문자열로 만든다
+// 매번 같은 내용이 만들어지도록 섹션과 옵션은 이름 순으로 정렬한다
+func renderMyCnf(header string, configs ...mysqlv1alpha1.MySQLConfig) string {
+	merged := map[string]map[string]string{}
+	for _, config := range configs {
+		for section, options := range config {
+			if merged[section] == nil {
+				merged[section] = map[string]string{}
+			}
+			for key, value := range options {
+				merged[section][key] = value
+			}
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(header + "\n")
+	for _, section := range sortedKeys(merged) {
+		b.WriteString("[" + section + "]\n")
+		options := merged[section]
+		keys := make([]string, 0, len(options))
+		for key := range options {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if options[key] == "" {
+				b.WriteString(key + "\n")
+			} else {
+				b.WriteString(key + "=" + options[key] + "\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// sortedKeys 는 섹션 이름을 정렬해서 리턴한다
+func sortedKeys(m map[string]map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
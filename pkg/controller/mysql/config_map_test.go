@@ -0,0 +1,49 @@
+package mysql
+
+import (
+	"testing"
+
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+func TestRenderMyCnf(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []mysqlv1alpha1.MySQLConfig
+		want    string
+	}{
+		{
+			name: "sorted sections and options",
+			configs: []mysqlv1alpha1.MySQLConfig{
+				{
+					"mysqld": {"server-id": "100", "log-bin": ""},
+					"client": {"port": "3306"},
+				},
+			},
+			want: "# header\n[client]\nport=3306\n[mysqld]\nlog-bin\nserver-id=100\n",
+		},
+		{
+			name: "later configs override earlier ones",
+			configs: []mysqlv1alpha1.MySQLConfig{
+				{"mysqld": {"max-connections": "151", "skip-name-resolve": ""}},
+				{"mysqld": {"max-connections": "500"}},
+			},
+			want: "# header\n[mysqld]\nmax-connections=500\nskip-name-resolve\n",
+		},
+		{
+			name: "nil configs are skipped",
+			configs: []mysqlv1alpha1.MySQLConfig{
+				nil,
+				{"mysqld": {"super-read-only": ""}},
+			},
+			want: "# header\n[mysqld]\nsuper-read-only\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderMyCnf("# header", tt.configs...); got != tt.want {
+				t.Errorf("renderMyCnf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
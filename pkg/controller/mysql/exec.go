@@ -0,0 +1,83 @@
+package mysql
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// mysqlContainerName 은 mysqld 를 실행하는 컨테이너의 이름이다
+const mysqlContainerName = "mysql"
+
+// execInPod 는 파드의 컨테이너에서 명령을 실행하고 표준 출력을 리턴한다. stdin 이 nil 이 아니면 명령의 표준 입력으로 보낸다
+func (r *ReconcileMySQL) execInPod(namespace, podName, container string, command []string, stdin io.Reader) (string, error) {
+	request := r.clientset.CoreV1().RESTClient().Post().
+		Namespace(namespace).
+		Resource("pods").
+		Name(podName).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdin:     stdin != nil,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(r.config, "POST", request.URL())
+	if err != nil {
+		return "", err
+	}
+	var stdout, stderr bytes.Buffer
+	if err := executor.Stream(remotecommand.StreamOptions{Stdin: stdin, Stdout: &stdout, Stderr: &stderr}); err != nil {
+		return "", fmt.Errorf("exec in pod %s failed: %v: %s", podName, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// runSQL 은 멤버의 mysqld 에 root 로 접속해서 query 를 실행하고, 결과를 열 이름을 키로 가진 행의 목록으로 리턴한다
+// query 는 비밀번호를 담을 수 있으므로 명령의 인자 대신 표준 입력으로 보낸다. 접속할 때의 비밀번호는 컨테이너의 환경변수에서 읽는다
+// 결과가 여러 개인 query 는 첫 번째 결과의 열 이름으로 모든 행을 읽으므로, 결과를 사용하려면 결과가 하나인 query 를 실행해야 한다
+func (r *ReconcileMySQL) runSQL(mysql *mysqlv1alpha1.MySQL, podName, query string) ([]map[string]string, error) {
+	command := []string{
+		"bash", "-c", `exec mysql -h 127.0.0.1 -uroot -p"${MYSQL_ROOT_PASSWORD}" --connect-timeout=5 --batch`,
+	}
+	output, err := r.execInPod(mysql.Namespace, podName, mysqlContainerName, command, strings.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	return parseRows(output), nil
+}
+
+// quoteSQL 은 문자열을 SQL 의 작은따옴표 문자열 리터럴로 만든다
+func quoteSQL(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, `'`, `''`, -1)
+	return "'" + value + "'"
+}
+
+// parseRows 는 mysql 클라이언트의 batch 모드 출력을 행의 목록으로 바꾼다. 첫 줄은 열 이름이고 각 열은 탭으로 구분된다
+func parseRows(output string) []map[string]string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) < 2 {
+		return nil
+	}
+	columns := strings.Split(lines[0], "\t")
+	rows := make([]map[string]string, 0, len(lines)-1)
+	for _, line := range lines[1:] {
+		values := strings.Split(line, "\t")
+		row := make(map[string]string, len(columns))
+		for i, column := range columns {
+			if i < len(values) {
+				row[column] = values[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
@@ -0,0 +1,58 @@
+package mysql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRows(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []map[string]string
+	}{
+		{
+			name:   "empty output",
+			output: "",
+			want:   nil,
+		},
+		{
+			name:   "columns without rows",
+			output: "File\tPosition\n",
+			want:   nil,
+		},
+		{
+			name:   "rows",
+			output: "Variable_name\tValue\nRpl_semi_sync_master_status\tON\nRpl_semi_sync_master_clients\t2\n",
+			want: []map[string]string{
+				{"Variable_name": "Rpl_semi_sync_master_status", "Value": "ON"},
+				{"Variable_name": "Rpl_semi_sync_master_clients", "Value": "2"},
+			},
+		},
+		{
+			name:   "missing trailing values",
+			output: "Master_Host\tLast_IO_Error\nmysql-0\n",
+			want:   []map[string]string{{"Master_Host": "mysql-0"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseRows(tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseRows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteSQL(t *testing.T) {
+	tests := map[string]string{
+		"password":  "'password'",
+		"it's":      "'it''s'",
+		`back\long`: `'back\\long'`,
+	}
+	for value, want := range tests {
+		if got := quoteSQL(value); got != want {
+			t.Errorf("quoteSQL(%q) = %s, want %s", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,498 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// 프라이머리를 바꾼 이유
+const (
+	failoverReasonPrimaryFailure = "PrimaryFailure"
+	failoverReasonSwitchover     = "Switchover"
+)
+
+const (
+	// failoverApplyTimeout 은 페일오버 중에 레플리카가 받아둔 릴레이 로그를 모두 적용하기를 기다리는 최대 시간이다
+	failoverApplyTimeout = 30 * time.Second
+	// failoverApplyCheckInterval 은 레플리카가 릴레이 로그를 모두 적용했는지 다시 확인하기까지 기다리는 시간이다
+	failoverApplyCheckInterval = 2 * time.Second
+	// failoverRetryInterval 은 승격할 수 있는 레플리카가 없을 때 다시 확인하기까지 기다리는 시간이다
+	failoverRetryInterval = 10 * time.Second
+)
+
+// syncPrimary 는 멤버가 역할에 맞게 쓰기를 받는지 확인하고, 프라이머리에 장애가 나면 레플리카를 프라이머리로 승격한다
+// 스펙에 프라이머리를 지정했다면 현재 프라이머리가 정상일 때 지정한 멤버로 프라이머리를 바꾼다
+// 프라이머리가 페일오버 타임아웃보다 오래 준비되지 않으면 장애로 판단하며, 그때까지는 다시 확인하도록 조정 루프를 예약한다
+func (r *ReconcileMySQL) syncPrimary(mysql *mysqlv1alpha1.MySQL) (reconcile.Result, error) {
+	klog.Infof("[%s] syncPrimary", mysql.Name)
+	pods, err := r.listPods(mysql)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	primaryName := getPrimaryPodName(mysql)
+	var primary *corev1.Pod
+	var candidates []string
+	for i := range pods {
+		switch {
+		case pods[i].Name == primaryName:
+			primary = &pods[i]
+		case isPodReady(&pods[i]) && !isDepartingMember(mysql, &pods[i]):
+			candidates = append(candidates, pods[i].Name)
+		}
+	}
+	sort.Strings(candidates)
+
+	if primary != nil && isPodReady(primary) {
+		// 페일오버를 기다리는 중에 프라이머리가 돌아왔다면 페일오버를 그만두며, 멈춘 IO 스레드는 syncReplication 이 다시 시작한다
+		r.failoverStartedAt.Delete(mysql.UID)
+		// 진행 중인 스위치오버는 이전 프라이머리의 쓰기를 막아 두었으므로 역할을 맞추기 전에 이어서 진행한다
+		// 그 사이에 스펙의 프라이머리가 지워졌다면 스위치오버를 그만두고, ensureRoles 가 이전 프라이머리의 쓰기를 다시 허용한다
+		target := getSwitchoverTarget(mysql)
+		if _, ok := r.switchoverStartedAt.Load(mysql.UID); ok {
+			if target != "" && target != primaryName {
+				return r.switchover(mysql, primaryName, target, pods)
+			}
+			r.switchoverStartedAt.Delete(mysql.UID)
+		}
+		if err := r.ensureRoles(mysql, primaryName, pods); err != nil {
+			return reconcile.Result{}, err
+		}
+		if err := r.repointReplicas(mysql, primaryName, pods); err != nil {
+			return reconcile.Result{}, err
+		}
+		if err := r.syncReplication(mysql, primaryName, pods); err != nil {
+			return reconcile.Result{}, err
+		}
+		// 스펙에 지정한 프라이머리가 현재 프라이머리와 다르면 프라이머리를 바꾼다
+		if target != "" && target != primaryName && mysql.Status.Phase != mysqlv1alpha1.MySQLPhaseCreating {
+			return r.switchover(mysql, primaryName, target, pods)
+		}
+		if mysql.Spec.IsSemiSyncEnabled() {
+			return reconcile.Result{RequeueAfter: semiSyncCheckInterval}, nil
+		}
+		return reconcile.Result{}, nil
+	}
+	// 프라이머리가 준비되지 않았다면 진행 중인 스위치오버를 그만두고 페일오버를 기다린다
+	r.switchoverStartedAt.Delete(mysql.UID)
+	// 한 번도 준비된 적이 없는 클러스터는 아직 만들어지는 중이므로 페일오버하지 않는다
+	if !mysql.Spec.IsFailoverEnabled() || mysql.Spec.GetReplicas() == 1 || mysql.Status.Phase == mysqlv1alpha1.MySQLPhaseCreating {
+		return reconcile.Result{}, nil
+	}
+	// 이미 시작한 페일오버는 이전 프라이머리를 막느라 파드가 다시 만들어졌을 수 있으므로 타임아웃을 다시 기다리지 않고 이어서 진행한다
+	if _, ok := r.failoverStartedAt.Load(mysql.UID); ok && len(candidates) > 0 {
+		return r.failover(mysql, primaryName, primary, candidates)
+	}
+
+	// 프라이머리가 준비되지 않은 시점은 상태의 PrimaryNotReadySince 에 기록된다
+	// 프라이머리 파드가 새로 만들어졌다면 롤링 업데이트나 재배치 중일 수 있으므로 파드가 만들어진 시점부터 기다린다
+	timeout := time.Duration(mysql.Spec.GetFailoverTimeoutSeconds()) * time.Second
+	if mysql.Status.PrimaryNotReadySince == nil {
+		return reconcile.Result{RequeueAfter: timeout}, nil
+	}
+	since := mysql.Status.PrimaryNotReadySince.Time
+	if primary != nil && primary.CreationTimestamp.Time.After(since) {
+		since = primary.CreationTimestamp.Time
+	}
+	if elapsed := time.Since(since); elapsed < timeout {
+		klog.Infof("[%s] Primary %s is not ready for %s", mysql.Name, primaryName, elapsed.Round(time.Second))
+		return reconcile.Result{RequeueAfter: timeout - elapsed}, nil
+	}
+	if len(candidates) == 0 {
+		klog.Infof("[%s] Primary %s is not ready, but there is no ready replica to promote", mysql.Name, primaryName)
+		return reconcile.Result{RequeueAfter: failoverRetryInterval}, nil
+	}
+	klog.Infof("[%s] Primary %s is not ready for %s. Start failover", mysql.Name, primaryName, timeout)
+	return r.failover(mysql, primaryName, primary, candidates)
+}
+
+// ensureRoles 는 준비된 멤버 중 프라이머리만 쓰기를 받고 레플리카는 읽기 전용이 되도록 한다
+// 반동기 복제를 사용하면 프라이머리만 레플리카의 응답을 기다리도록 한다
+// 멤버의 역할은 파드가 시작될 때의 설정 파일로 정해지므로, 프라이머리가 바뀐 뒤에 mysqld 만 다시 시작되면 이전 역할의 설정으로 시작한다
+func (r *ReconcileMySQL) ensureRoles(mysql *mysqlv1alpha1.MySQL, primaryName string, pods []corev1.Pod) error {
+	for i := range pods {
+		if !isPodReady(&pods[i]) {
+			continue
+		}
+		if err := r.setReadOnly(mysql, pods[i].Name, pods[i].Name != primaryName); err != nil {
+			return err
+		}
+		if mysql.Spec.IsSemiSyncEnabled() {
+			if err := r.setSemiSyncPrimary(mysql, pods[i].Name, pods[i].Name == primaryName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setReadOnly 는 멤버의 읽기 전용 여부를 바꾼다. 읽기 전용인 멤버는 root 의 쓰기도 막기 위해 super_read_only 를 켠다
+func (r *ReconcileMySQL) setReadOnly(mysql *mysqlv1alpha1.MySQL, podName string, readOnly bool) error {
+	rows, err := r.runSQL(mysql, podName, "SELECT @@global.read_only AS read_only, @@global.super_read_only AS super_read_only")
+	if err != nil {
+		return err
+	}
+	if len(rows) == 1 {
+		if readOnly && rows[0]["super_read_only"] == "1" ||
+			!readOnly && rows[0]["read_only"] == "0" && rows[0]["super_read_only"] == "0" {
+			return nil
+		}
+	}
+	if readOnly {
+		klog.Infof("[%s] Make %s read only", mysql.Name, podName)
+		_, err = r.runSQL(mysql, podName, "SET GLOBAL super_read_only = ON")
+		return err
+	}
+	klog.Infof("[%s] Make %s writable", mysql.Name, podName)
+	_, err = r.runSQL(mysql, podName, "SET GLOBAL super_read_only = OFF; SET GLOBAL read_only = OFF")
+	return err
+}
+
+// failover 는 레플리카 중 프라이머리의 바이너리 로그를 가장 많이 적용한 레플리카를 프라이머리로 승격하고,
+// 같은 위치까지 적용한 다른 레플리카가 새로운 프라이머리를 복제하도록 바꾼다
+// 새로운 프라이머리보다 뒤처진 레플리카와 이전 프라이머리는 새로운 프라이머리의 바이너리 로그에서 이어서 복제할 위치를 알 수 없으므로 다시 복제(clone)해야 한다
+// GTID 모드에서는 레플리카가 빠진 트랜잭션을 새로운 프라이머리에서 찾아 받으므로 모든 레플리카가 새로운 프라이머리를 복제하며,
+// 이전 프라이머리는 다시 준비되면 repointReplicas 가 레플리카로 만든다
+// 레플리카가 릴레이 로그를 적용하기를 기다리는 동안에는 조정 루프를 붙잡지 않고 다시 예약하며, 시작한 시점은 failoverStartedAt 에 기억한다
+func (r *ReconcileMySQL) failover(mysql *mysqlv1alpha1.MySQL, oldPrimary string, primary *corev1.Pod, candidates []string) (reconcile.Result, error) {
+	gtid := mysql.Spec.GetReplicationMode() == mysqlv1alpha1.ReplicationModeGTID
+	started, ok := r.failoverStartedAt.Load(mysql.UID)
+	if !ok {
+		// 레플리카를 승격하기 전에 이전 프라이머리가 쓰기를 받지 못하게 막는다. 막지 못하면 승격하지 않는다
+		if err := r.fencePrimary(mysql, primary); err != nil {
+			return reconcile.Result{}, fmt.Errorf("failover from %s stopped: could not fence the old primary: %v", oldPrimary, err)
+		}
+		started = time.Now()
+		r.failoverStartedAt.Store(mysql.UID, started)
+	}
+
+	// 장애가 난 프라이머리로부터 더 이상 받지 않도록 IO 스레드를 멈추고 적용한 위치를 확인한다
+	positions := map[string]*replicaPosition{}
+	for _, name := range candidates {
+		if _, err := r.runSQL(mysql, name, "STOP SLAVE IO_THREAD"); err != nil {
+			klog.Errorf("[%s] Could not stop replication on %s: %v", mysql.Name, name, err)
+			continue
+		}
+		position, err := r.getReplicaPosition(mysql, name)
+		if err != nil {
+			klog.Errorf("[%s] Could not get replication position of %s: %v", mysql.Name, name, err)
+			continue
+		}
+		positions[name] = position
+	}
+
+	// 레플리카가 받아둔 릴레이 로그를 모두 적용할 때까지 기다린다. 시간 안에 적용하지 못하면 그때까지 적용한 위치로 판단한다
+	if elapsed := time.Since(started.(time.Time)); elapsed < failoverApplyTimeout && !isRelayLogApplied(positions) {
+		klog.Infof("[%s] Waiting for replicas to apply relay logs before failover (%s)", mysql.Name, elapsed.Round(time.Second))
+		return reconcile.Result{RequeueAfter: failoverApplyCheckInterval}, nil
+	}
+	r.failoverStartedAt.Delete(mysql.UID)
+
+	newPrimary := selectNewPrimary(candidates, positions)
+	if newPrimary == "" {
+		return reconcile.Result{}, fmt.Errorf("failover from %s failed: no replica can be promoted", oldPrimary)
+	}
+	// 기다리는 동안 이전 프라이머리의 파드가 다시 만들어졌을 수 있으므로 승격하기 직전에 한 번 더 막는다
+	if err := r.fencePrimary(mysql, primary); err != nil {
+		return reconcile.Result{}, fmt.Errorf("failover from %s stopped: could not fence the old primary: %v", oldPrimary, err)
+	}
+
+	klog.Infof("[%s] Promote %s to primary", mysql.Name, newPrimary)
+	file, position, err := r.promote(mysql, newPrimary)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	password, err := r.getSystemUserPassword(mysql, replicationUser)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	var stale []string
+	for _, name := range candidates {
+		if name == newPrimary {
+			continue
+		}
+		if !gtid && (positions[name] == nil || positions[name].compareExecuted(positions[newPrimary]) != 0) {
+			stale = append(stale, name)
+			continue
+		}
+		klog.Infof("[%s] Repoint %s to new primary %s", mysql.Name, name, newPrimary)
+		if err := r.changePrimary(mysql, name, newPrimary, password, file, position); err != nil {
+			klog.Errorf("[%s] Could not repoint %s to new primary: %v", mysql.Name, name, err)
+			stale = append(stale, name)
+		}
+	}
+
+	message := fmt.Sprintf("%s must be re-cloned before it rejoins", oldPrimary)
+	if len(stale) > 0 {
+		message = fmt.Sprintf("%s and %s must be re-cloned before they rejoin", oldPrimary, strings.Join(stale, ", "))
+	}
+	if gtid {
+		message = fmt.Sprintf("%s rejoins as a replica when it is ready unless it has errant transactions", oldPrimary)
+		if len(stale) > 0 {
+			message += fmt.Sprintf("; %s could not be repointed to the new primary", strings.Join(stale, ", "))
+		}
+	}
+	return reconcile.Result{}, r.recordFailover(mysql, &mysqlv1alpha1.FailoverRecord{
+		Time:       metav1.Now(),
+		OldPrimary: oldPrimary,
+		NewPrimary: newPrimary,
+		Reason:     failoverReasonPrimaryFailure,
+		Message:    message,
+	})
+}
+
+// fencePrimary 는 레플리카를 승격하기 전에 이전 프라이머리가 더 이상 쓰기를 받지 못하게 막는다
+// 준비 상태 확인에만 실패하고 mysqld 는 살아있는 프라이머리가 새로운 프라이머리와 함께 쓰기를 받으면 데이터가 갈라지므로(split-brain),
+// super_read_only 를 켜서 이미 열린 연결의 쓰기까지 막고, 켜지 못하면 파드를 삭제한다. 둘 다 실패하면 에러를 리턴한다
+func (r *ReconcileMySQL) fencePrimary(mysql *mysqlv1alpha1.MySQL, primary *corev1.Pod) error {
+	if primary == nil || primary.DeletionTimestamp != nil {
+		return nil
+	}
+	if isContainerRunning(primary, mysqlContainerName) {
+		err := r.setReadOnly(mysql, primary.Name, true)
+		if err == nil {
+			return nil
+		}
+		klog.Errorf("[%s] Could not make old primary %s read only: %v", mysql.Name, primary.Name, err)
+	}
+	klog.Infof("[%s] Delete old primary %s to fence it", mysql.Name, primary.Name)
+	if err := r.client.Delete(context.TODO(), primary); err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// isRelayLogApplied 는 복제 위치를 확인한 레플리카가 모두 받아둔 릴레이 로그를 적용했는지 리턴한다
+// SQL 스레드가 멈춘 레플리카는 더 기다려도 적용하지 않으므로 기다리지 않는다
+func isRelayLogApplied(positions map[string]*replicaPosition) bool {
+	for _, position := range positions {
+		if position.sqlRunning && !position.isApplied() {
+			return false
+		}
+	}
+	return true
+}
+
+// selectNewPrimary 는 복제 위치를 확인한 레플리카 중 프라이머리의 바이너리 로그를 가장 많이 적용한 레플리카를 리턴한다
+// 같은 위치까지 적용한 레플리카가 여럿이면 candidates 에서 앞선 레플리카를 고르며, 고를 레플리카가 없으면 빈 문자열을 리턴한다
+func selectNewPrimary(candidates []string, positions map[string]*replicaPosition) string {
+	newPrimary := ""
+	for _, name := range candidates {
+		if positions[name] == nil {
+			continue
+		}
+		if newPrimary == "" || positions[name].compareExecuted(positions[newPrimary]) > 0 {
+			newPrimary = name
+		}
+	}
+	return newPrimary
+}
+
+// promote 는 레플리카의 복제를 끊고 쓰기를 허용해서 프라이머리로 만든다
+// 쓰기를 허용하기 전의 바이너리 로그 위치를 리턴하며, 다른 레플리카는 이 위치부터 복제를 시작한다
+func (r *ReconcileMySQL) promote(mysql *mysqlv1alpha1.MySQL, podName string) (string, int64, error) {
+	if _, err := r.runSQL(mysql, podName, "STOP SLAVE; RESET SLAVE ALL"); err != nil {
+		return "", 0, err
+	}
+	file, position, err := r.getBinlogPosition(mysql, podName)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := r.setReadOnly(mysql, podName, false); err != nil {
+		return "", 0, err
+	}
+	return file, position, nil
+}
+
+// getBinlogPosition 는 SHOW MASTER STATUS 로 멤버가 쓰고 있는 바이너리 로그의 위치를 가져온다
+func (r *ReconcileMySQL) getBinlogPosition(mysql *mysqlv1alpha1.MySQL, podName string) (string, int64, error) {
+	rows, err := r.runSQL(mysql, podName, "SHOW MASTER STATUS")
+	if err != nil {
+		return "", 0, err
+	}
+	if len(rows) == 0 {
+		return "", 0, fmt.Errorf("binary log is not enabled on %s", podName)
+	}
+	position, err := strconv.ParseInt(rows[0]["Position"], 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+	return rows[0]["File"], position, nil
+}
+
+// changePrimary 는 레플리카가 primaryName 멤버의 바이너리 로그를 file, position 위치부터 복제하도록 바꾼다
+// GTID 모드에서는 레플리카가 가지지 않은 트랜잭션부터 복제하므로 file, position 을 사용하지 않는다
+func (r *ReconcileMySQL) changePrimary(mysql *mysqlv1alpha1.MySQL, podName, primaryName, password, file string, position int64) error {
+	from := fmt.Sprintf("MASTER_LOG_FILE=%s, MASTER_LOG_POS=%d", quoteSQL(file), position)
+	if mysql.Spec.GetReplicationMode() == mysqlv1alpha1.ReplicationModeGTID {
+		from = "MASTER_AUTO_POSITION=1"
+	}
+	query := fmt.Sprintf("STOP SLAVE; CHANGE MASTER TO MASTER_HOST=%s, MASTER_USER=%s, MASTER_PASSWORD=%s, "+
+		"%s, MASTER_CONNECT_RETRY=10%s; START SLAVE",
+		quoteSQL(getMemberHost(mysql, primaryName)), quoteSQL(replicationUser.name), quoteSQL(password), from, getPrimaryAuthOptions(mysql))
+	_, err := r.runSQL(mysql, podName, query)
+	return err
+}
+
+// repointReplicas 는 GTID 모드에서 현재 프라이머리를 복제하지 않는 준비된 레플리카가 프라이머리를 복제하도록 바꾼다
+// 페일오버 때 준비되지 않았던 레플리카나 장애에서 돌아온 이전 프라이머리가 대상이며,
+// 프라이머리에 없는 트랜잭션(errant transaction)을 가진 멤버는 데이터가 어긋났으므로 바꾸지 않고 다시 복제(clone)해야 한다
+func (r *ReconcileMySQL) repointReplicas(mysql *mysqlv1alpha1.MySQL, primaryName string, pods []corev1.Pod) error {
+	if mysql.Spec.GetReplicationMode() != mysqlv1alpha1.ReplicationModeGTID {
+		return nil
+	}
+	primaryHost := getMemberHost(mysql, primaryName)
+	primaryExecuted := ""
+	for i := range pods {
+		name := pods[i].Name
+		if name == primaryName || !isPodReady(&pods[i]) || isDepartingMember(mysql, &pods[i]) {
+			continue
+		}
+		rows, err := r.runSQL(mysql, name, "SHOW SLAVE STATUS")
+		if err != nil {
+			return err
+		}
+		if len(rows) > 0 && rows[0]["Master_Host"] == primaryHost {
+			continue
+		}
+		// batch 모드는 값의 줄바꿈을 이스케이프하므로 GTID 집합의 줄바꿈을 지우고 가져온다
+		if primaryExecuted == "" {
+			rows, err := r.runSQL(mysql, primaryName, `SELECT REPLACE(@@global.gtid_executed, '\n', '') AS gtid_executed`)
+			if err != nil {
+				return err
+			}
+			if len(rows) == 0 {
+				return fmt.Errorf("could not get executed GTID set of primary %s", primaryName)
+			}
+			primaryExecuted = rows[0]["gtid_executed"]
+		}
+		rows, err = r.runSQL(mysql, name,
+			fmt.Sprintf("SELECT GTID_SUBSET(@@global.gtid_executed, %s) AS subset", quoteSQL(primaryExecuted)))
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 || rows[0]["subset"] != "1" {
+			klog.Errorf("[%s] %s has transactions that primary %s does not have and must be re-cloned", mysql.Name, name, primaryName)
+			continue
+		}
+		password, err := r.getSystemUserPassword(mysql, replicationUser)
+		if err != nil {
+			return err
+		}
+		klog.Infof("[%s] Repoint %s to primary %s", mysql.Name, name, primaryName)
+		if err := r.changePrimary(mysql, name, primaryName, password, "", 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordFailover 는 새로운 프라이머리와 프라이머리를 바꾼 기록을 상태에 저장하고, 컨피그맵의 프라이머리를 바꾼다
+// 상태를 먼저 저장해야 이후의 조정 루프가 새로운 프라이머리를 기준으로 동작한다
+func (r *ReconcileMySQL) recordFailover(mysql *mysqlv1alpha1.MySQL, record *mysqlv1alpha1.FailoverRecord) error {
+	klog.Infof("[%s] Primary changed from %s to %s (%s)", mysql.Name, record.OldPrimary, record.NewPrimary, record.Reason)
+	mysql.Status.CurrentPrimary = record.NewPrimary
+	record.ObservedGeneration = mysql.Generation
+	mysql.Status.LastFailover = record
+	if err := r.client.Status().Update(context.TODO(), mysql); err != nil {
+		return err
+	}
+	return r.syncConfigMap(mysql)
+}
+
+// replicaPosition 은 레플리카가 프라이머리의 바이너리 로그를 어디까지 받았고 어디까지 적용했는지 나타낸다
+// secondsBehind 는 복제 지연이며, SQL 스레드가 멈췄거나 IO 스레드가 프라이머리에 접속하지 못해서 알 수 없으면 nil 이다
+// ioState 와 sqlState 는 각 스레드의 상태(Yes, No, Connecting)이고, 에러 번호가 0 이 아니면 그 스레드의 마지막 에러이다
+type replicaPosition struct {
+	primaryHost   string
+	ioRunning     bool
+	readFile      string
+	readPosition  int64
+	execFile      string
+	execPosition  int64
+	sqlRunning    bool
+	secondsBehind *int64
+	ioState       string
+	ioErrno       int
+	ioError       string
+	sqlState      string
+	sqlErrno      int
+	sqlError      string
+}
+
+// getReplicaPosition 는 SHOW SLAVE STATUS 로 레플리카의 복제 위치를 가져온다
+func (r *ReconcileMySQL) getReplicaPosition(mysql *mysqlv1alpha1.MySQL, podName string) (*replicaPosition, error) {
+	rows, err := r.runSQL(mysql, podName, "SHOW SLAVE STATUS")
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, &notReplicatingError{podName: podName}
+	}
+	readPosition, err := strconv.ParseInt(rows[0]["Read_Master_Log_Pos"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	execPosition, err := strconv.ParseInt(rows[0]["Exec_Master_Log_Pos"], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	position := &replicaPosition{
+		primaryHost:  rows[0]["Master_Host"],
+		ioRunning:    rows[0]["Slave_IO_Running"] == "Yes",
+		readFile:     rows[0]["Master_Log_File"],
+		readPosition: readPosition,
+		execFile:     rows[0]["Relay_Master_Log_File"],
+		execPosition: execPosition,
+		sqlRunning:   rows[0]["Slave_SQL_Running"] == "Yes",
+		ioState:      rows[0]["Slave_IO_Running"],
+		ioError:      rows[0]["Last_IO_Error"],
+		sqlState:     rows[0]["Slave_SQL_Running"],
+		sqlError:     rows[0]["Last_SQL_Error"],
+	}
+	position.ioErrno, _ = strconv.Atoi(rows[0]["Last_IO_Errno"])
+	position.sqlErrno, _ = strconv.Atoi(rows[0]["Last_SQL_Errno"])
+	if secondsBehind, err := strconv.ParseInt(rows[0]["Seconds_Behind_Master"], 10, 64); err == nil {
+		position.secondsBehind = &secondsBehind
+	}
+	return position, nil
+}
+
+// notReplicatingError 는 멤버에 복제 설정이 없어서 SHOW SLAVE STATUS 가 비어 있는 경우의 에러이다
+type notReplicatingError struct {
+	podName string
+}
+
+func (e *notReplicatingError) Error() string {
+	return fmt.Sprintf("%s is not replicating", e.podName)
+}
+
+// isApplied 는 받아둔 릴레이 로그를 모두 적용했는지 리턴한다
+func (p *replicaPosition) isApplied() bool {
+	return p.readFile == p.execFile && p.readPosition == p.execPosition
+}
+
+// compareExecuted 는 적용한 위치를 비교해서 p 가 더 앞서면 양수, 같으면 0, 뒤처지면 음수를 리턴한다
+// 바이너리 로그 파일 이름은 같은 접두사와 0 으로 채운 번호로 이루어지므로 문자열로 비교할 수 있다
+func (p *replicaPosition) compareExecuted(other *replicaPosition) int {
+	if p.execFile != other.execFile {
+		return strings.Compare(p.execFile, other.execFile)
+	}
+	switch {
+	case p.execPosition > other.execPosition:
+		return 1
+	case p.execPosition < other.execPosition:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,179 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestReplicaPositionIsApplied(t *testing.T) {
+	tests := []struct {
+		name     string
+		position replicaPosition
+		want     bool
+	}{
+		{
+			name:     "applied",
+			position: replicaPosition{readFile: "mysql-bin.000002", readPosition: 154, execFile: "mysql-bin.000002", execPosition: 154},
+			want:     true,
+		},
+		{
+			name:     "behind in the same file",
+			position: replicaPosition{readFile: "mysql-bin.000002", readPosition: 900, execFile: "mysql-bin.000002", execPosition: 154},
+			want:     false,
+		},
+		{
+			name:     "behind in a previous file",
+			position: replicaPosition{readFile: "mysql-bin.000003", readPosition: 154, execFile: "mysql-bin.000002", execPosition: 154},
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.position.isApplied(); got != tt.want {
+				t.Errorf("isApplied() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplicaPositionCompareExecuted(t *testing.T) {
+	tests := []struct {
+		name  string
+		p     replicaPosition
+		other replicaPosition
+		want  int
+	}{
+		{
+			name:  "same position",
+			p:     replicaPosition{execFile: "mysql-bin.000002", execPosition: 154},
+			other: replicaPosition{execFile: "mysql-bin.000002", execPosition: 154},
+			want:  0,
+		},
+		{
+			name:  "ahead in the same file",
+			p:     replicaPosition{execFile: "mysql-bin.000002", execPosition: 900},
+			other: replicaPosition{execFile: "mysql-bin.000002", execPosition: 154},
+			want:  1,
+		},
+		{
+			name:  "behind in the same file",
+			p:     replicaPosition{execFile: "mysql-bin.000002", execPosition: 154},
+			other: replicaPosition{execFile: "mysql-bin.000002", execPosition: 900},
+			want:  -1,
+		},
+		{
+			name:  "later file wins over larger position",
+			p:     replicaPosition{execFile: "mysql-bin.000010", execPosition: 4},
+			other: replicaPosition{execFile: "mysql-bin.000009", execPosition: 900},
+			want:  1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.p.compareExecuted(&tt.other)
+			if (got > 0) != (tt.want > 0) || (got < 0) != (tt.want < 0) {
+				t.Errorf("compareExecuted() = %d, want sign of %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectNewPrimary(t *testing.T) {
+	position := func(file string, pos int64) *replicaPosition {
+		return &replicaPosition{execFile: file, execPosition: pos}
+	}
+	tests := []struct {
+		name       string
+		candidates []string
+		positions  map[string]*replicaPosition
+		want       string
+	}{
+		{
+			name:       "most applied replica",
+			candidates: []string{"mysql-1", "mysql-2"},
+			positions: map[string]*replicaPosition{
+				"mysql-1": position("mysql-bin.000002", 154),
+				"mysql-2": position("mysql-bin.000002", 900),
+			},
+			want: "mysql-2",
+		},
+		{
+			name:       "first candidate on a tie",
+			candidates: []string{"mysql-1", "mysql-2"},
+			positions: map[string]*replicaPosition{
+				"mysql-1": position("mysql-bin.000002", 900),
+				"mysql-2": position("mysql-bin.000002", 900),
+			},
+			want: "mysql-1",
+		},
+		{
+			name:       "skip replicas without a position",
+			candidates: []string{"mysql-1", "mysql-2"},
+			positions: map[string]*replicaPosition{
+				"mysql-1": nil,
+				"mysql-2": position("mysql-bin.000001", 154),
+			},
+			want: "mysql-2",
+		},
+		{
+			name:       "no replica to promote",
+			candidates: []string{"mysql-1"},
+			positions:  map[string]*replicaPosition{},
+			want:       "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectNewPrimary(tt.candidates, tt.positions); got != tt.want {
+				t.Errorf("selectNewPrimary() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsRelayLogApplied(t *testing.T) {
+	applied := &replicaPosition{sqlRunning: true, readFile: "mysql-bin.000002", readPosition: 900, execFile: "mysql-bin.000002", execPosition: 900}
+	behind := &replicaPosition{sqlRunning: true, readFile: "mysql-bin.000002", readPosition: 900, execFile: "mysql-bin.000002", execPosition: 154}
+	stopped := &replicaPosition{readFile: "mysql-bin.000002", readPosition: 900, execFile: "mysql-bin.000002", execPosition: 154}
+	tests := []struct {
+		name      string
+		positions map[string]*replicaPosition
+		want      bool
+	}{
+		{name: "all applied", positions: map[string]*replicaPosition{"mysql-1": applied, "mysql-2": applied}, want: true},
+		{name: "one behind", positions: map[string]*replicaPosition{"mysql-1": applied, "mysql-2": behind}, want: false},
+		{name: "stopped SQL thread is not waited for", positions: map[string]*replicaPosition{"mysql-1": applied, "mysql-2": stopped}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRelayLogApplied(tt.positions); got != tt.want {
+				t.Errorf("isRelayLogApplied() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFencePrimaryDeletesUnreachablePrimary(t *testing.T) {
+	mysql := &mysqlv1alpha1.MySQL{ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: "default"}}
+	// mysql 컨테이너가 실행 중이 아니면 읽기 전용으로 바꿀 수 없으므로 파드를 삭제한다
+	primary := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "mysql-0", Namespace: "default"}}
+	r := &ReconcileMySQL{client: fake.NewFakeClientWithScheme(scheme.Scheme, primary.DeepCopy())}
+	if err := r.fencePrimary(mysql, primary); err != nil {
+		t.Fatal(err)
+	}
+	err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "mysql-0"}, &corev1.Pod{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("old primary pod is not deleted: %v", err)
+	}
+	if err := r.fencePrimary(mysql, nil); err != nil {
+		t.Errorf("fencePrimary() without a primary pod = %v, want nil", err)
+	}
+}
@@ -0,0 +1,204 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// dataCleanupFinalizer 는 MySQL 객체가 삭제되기 전에 삭제 정책을 적용하기 위한 파이널라이저이다
+const dataCleanupFinalizer = "mysql.woohhan.com/data-cleanup"
+
+// snapshotPollInterval 은 볼륨 스냅샷이 준비되었는지 다시 확인하기까지 기다리는 시간이다
+const snapshotPollInterval = 10 * time.Second
+
+// volumeSnapshotGVK 는 CSI 볼륨 스냅샷의 그룹, 버전, 종류이다
+// 스냅샷 CRD 는 클러스터에 따라 설치되어 있지 않을 수 있으므로 타입이 있는 클라이언트 대신 unstructured 로 다룬다
+var volumeSnapshotGVK = schema.GroupVersionKind{Group: "snapshot.storage.k8s.io", Version: "v1beta1", Kind: "VolumeSnapshot"}
+
+// syncFinalizer 는 MySQL 객체에 파이널라이저를 붙인다
+// Retain 정책도 데이터 볼륨 클레임과 함께 비밀번호 시크릿을 남겨야 하므로, 삭제 정책과 관계없이 가비지 컬렉터보다 먼저 삭제 정책을 적용하도록 파이널라이저를 붙인다
+func (r *ReconcileMySQL) syncFinalizer(mysql *mysqlv1alpha1.MySQL) error {
+	if hasFinalizer(mysql) {
+		return nil
+	}
+	klog.Infof("[%s] Add finalizer %s", mysql.Name, dataCleanupFinalizer)
+	controllerutil.AddFinalizer(mysql, dataCleanupFinalizer)
+	return r.client.Update(context.TODO(), mysql)
+}
+
+// hasFinalizer 는 MySQL 객체에 파이널라이저가 붙어있는지 리턴한다
+func hasFinalizer(mysql *mysqlv1alpha1.MySQL) bool {
+	for _, finalizer := range mysql.Finalizers {
+		if finalizer == dataCleanupFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// reconcileDeletion 은 삭제 중인 MySQL 객체에 삭제 정책을 적용하고, 완료되면 파이널라이저를 제거해서 삭제를 마무리한다
+// 정책을 적용하는 동안의 진행 상황은 상태에 기록한다
+func (r *ReconcileMySQL) reconcileDeletion(mysql *mysqlv1alpha1.MySQL) (reconcile.Result, error) {
+	if !hasFinalizer(mysql) {
+		return reconcile.Result{}, nil
+	}
+	policy := mysql.Spec.GetDeletionPolicy()
+	klog.Infof("[%s] Apply deletion policy %s", mysql.Name, policy)
+
+	done, message, cleanupErr := r.cleanupData(mysql, policy)
+	if cleanupErr != nil {
+		message = cleanupErr.Error()
+	}
+	if err := r.updateTerminatingStatus(mysql, policy, message); err != nil {
+		return reconcile.Result{}, err
+	}
+	if cleanupErr != nil {
+		return reconcile.Result{}, cleanupErr
+	}
+	if !done {
+		return reconcile.Result{RequeueAfter: snapshotPollInterval}, nil
+	}
+
+	klog.Infof("[%s] Remove finalizer %s", mysql.Name, dataCleanupFinalizer)
+	controllerutil.RemoveFinalizer(mysql, dataCleanupFinalizer)
+	return reconcile.Result{}, r.client.Update(context.TODO(), mysql)
+}
+
+// cleanupData 는 삭제 정책에 따라 데이터 볼륨 클레임을 정리한다
+// 데이터 볼륨 클레임을 남겨두는 정책이면 데이터 디렉터리의 계정이 가진 비밀번호도 남도록 오퍼레이터가 만든 시크릿을 남겨둔다
+// 볼륨 스냅샷이 아직 준비되지 않았다면 done 이 false 이며, message 는 진행 상황이다
+func (r *ReconcileMySQL) cleanupData(mysql *mysqlv1alpha1.MySQL, policy mysqlv1alpha1.DeletionPolicy) (bool, string, error) {
+	if policy == mysqlv1alpha1.DeletionPolicyRetain {
+		if err := r.orphanCredentialSecrets(mysql); err != nil {
+			return false, "", err
+		}
+		return true, "retaining data volume claims and credential secrets", nil
+	}
+	claims, err := r.listDataVolumeClaims(mysql)
+	if err != nil {
+		return false, "", err
+	}
+
+	// 스냅샷을 만드는 정책이라면 모든 볼륨의 스냅샷이 준비된 다음에 볼륨 클레임을 삭제한다
+	if policy == mysqlv1alpha1.DeletionPolicySnapshot {
+		pending := 0
+		for i := range claims {
+			ready, err := r.ensureVolumeSnapshot(mysql, &claims[i])
+			if err != nil {
+				return false, "", err
+			}
+			if !ready {
+				pending++
+			}
+		}
+		if pending > 0 {
+			return false, fmt.Sprintf("waiting for %d of %d volume snapshots to be ready", pending, len(claims)), nil
+		}
+	}
+
+	for i := range claims {
+		klog.Infof("[%s] Delete data volume claim %s", mysql.Name, claims[i].Name)
+		if err := r.client.Delete(context.TODO(), &claims[i]); err != nil && !errors.IsNotFound(err) {
+			return false, "", err
+		}
+	}
+	return true, fmt.Sprintf("deleted %d data volume claims", len(claims)), nil
+}
+
+// listDataVolumeClaims 는 mysql 멤버의 데이터 볼륨 클레임 목록을 가져온다
+// 스테이트풀셋은 볼륨 클레임에 파드 셀렉터의 레이블을 붙이므로 같은 셀렉터로 찾을 수 있다
+func (r *ReconcileMySQL) listDataVolumeClaims(mysql *mysqlv1alpha1.MySQL) ([]corev1.PersistentVolumeClaim, error) {
+	claimList := &corev1.PersistentVolumeClaimList{}
+	if err := r.client.List(context.TODO(), claimList,
+		client.InNamespace(mysql.Namespace), client.MatchingLabels(selectorForMySQL(mysql))); err != nil {
+		return nil, err
+	}
+	return claimList.Items, nil
+}
+
+// ensureVolumeSnapshot 는 볼륨 클레임의 스냅샷이 없으면 만들고, 스냅샷이 사용할 준비가 되었는지 리턴한다
+// 스냅샷은 MySQL 객체가 삭제된 뒤에도 남아야 하므로 오너를 지정하지 않는다
+func (r *ReconcileMySQL) ensureVolumeSnapshot(mysql *mysqlv1alpha1.MySQL, claim *corev1.PersistentVolumeClaim) (bool, error) {
+	name := getVolumeSnapshotName(mysql, claim)
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: claim.Namespace, Name: name}, snapshot); err != nil {
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+		klog.Infof("[%s] Create volume snapshot %s", mysql.Name, name)
+		snapshot, err = newVolumeSnapshot(mysql, claim)
+		if err != nil {
+			return false, err
+		}
+		if err := r.client.Create(context.TODO(), snapshot); err != nil && !errors.IsAlreadyExists(err) {
+			return false, err
+		}
+		return false, nil
+	}
+	ready, _, err := unstructured.NestedBool(snapshot.Object, "status", "readyToUse")
+	return ready, err
+}
+
+// getVolumeSnapshotName 은 볼륨 클레임의 스냅샷 이름을 리턴한다
+// 같은 이름의 MySQL 객체를 다시 만들었다 지워도 이전 스냅샷과 겹치지 않도록 MySQL 객체의 UID 를 붙인다
+func getVolumeSnapshotName(mysql *mysqlv1alpha1.MySQL, claim *corev1.PersistentVolumeClaim) string {
+	uid := string(mysql.UID)
+	if len(uid) > 8 {
+		uid = uid[:8]
+	}
+	return claim.Name + "-" + uid
+}
+
+// newVolumeSnapshot 은 볼륨 클레임의 스냅샷을 위한 객체를 생성한다
+func newVolumeSnapshot(mysql *mysqlv1alpha1.MySQL, claim *corev1.PersistentVolumeClaim) (*unstructured.Unstructured, error) {
+	snapshot := &unstructured.Unstructured{}
+	snapshot.SetGroupVersionKind(volumeSnapshotGVK)
+	snapshot.SetName(getVolumeSnapshotName(mysql, claim))
+	snapshot.SetNamespace(claim.Namespace)
+	snapshot.SetLabels(labelsForMySQL(mysql, componentDatabase))
+	spec := map[string]interface{}{
+		"source": map[string]interface{}{
+			"persistentVolumeClaimName": claim.Name,
+		},
+	}
+	if storage := mysql.Spec.Storage; storage != nil && storage.VolumeSnapshotClassName != nil {
+		spec["volumeSnapshotClassName"] = *storage.VolumeSnapshotClassName
+	}
+	if err := unstructured.SetNestedMap(snapshot.Object, spec, "spec"); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// updateTerminatingStatus 는 삭제 정책과 그 진행 상황을 상태에 기록한다
+func (r *ReconcileMySQL) updateTerminatingStatus(mysql *mysqlv1alpha1.MySQL, policy mysqlv1alpha1.DeletionPolicy, message string) error {
+	newStatus := mysql.Status.DeepCopy()
+	newStatus.Phase = mysqlv1alpha1.MySQLPhaseTerminating
+	newStatus.DeletionPolicy = policy
+	newStatus.Conditions.SetCondition(status.Condition{
+		Type:    mysqlv1alpha1.ConditionTerminating,
+		Status:  corev1.ConditionTrue,
+		Reason:  status.ConditionReason(policy),
+		Message: message,
+	})
+	if equality.Semantic.DeepEqual(&mysql.Status, newStatus) {
+		return nil
+	}
+	mysql.Status = *newStatus
+	return r.client.Status().Update(context.TODO(), mysql)
+}
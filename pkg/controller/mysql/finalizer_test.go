@@ -0,0 +1,95 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRetainKeepsCredentialSecrets(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := mysqlv1alpha1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	name := types.NamespacedName{Namespace: "default", Name: "mysql"}
+	old := &mysqlv1alpha1.MySQL{ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace, UID: "old"}}
+	r := &ReconcileMySQL{client: fake.NewFakeClientWithScheme(scheme, old), scheme: scheme}
+
+	// 기본 삭제 정책(Retain)으로 만든 클러스터도 파이널라이저를 가진다
+	if err := r.syncFinalizer(old); err != nil {
+		t.Fatal(err)
+	}
+	if !hasFinalizer(old) {
+		t.Fatalf("finalizer is not added with the %s policy", old.Spec.GetDeletionPolicy())
+	}
+	if err := r.syncRootPasswordSecret(old); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.syncSystemUserSecrets(old); err != nil {
+		t.Fatal(err)
+	}
+	passwords := getSecretPasswords(t, r, old)
+
+	// 삭제 정책을 적용하면 시크릿은 오너 레퍼런스를 잃어서 가비지 컬렉션되지 않는다
+	now := metav1.Now()
+	old.DeletionTimestamp = &now
+	if _, err := r.reconcileDeletion(old); err != nil {
+		t.Fatal(err)
+	}
+	if hasFinalizer(old) {
+		t.Errorf("finalizer is not removed after applying the deletion policy")
+	}
+	for _, secretName := range getCredentialSecretNames(old) {
+		secret := &corev1.Secret{}
+		if err := r.client.Get(context.TODO(), secretName, secret); err != nil {
+			t.Fatal(err)
+		}
+		if len(secret.OwnerReferences) != 0 {
+			t.Errorf("secret %s is still owned by %v", secretName.Name, secret.OwnerReferences)
+		}
+	}
+
+	// 같은 이름으로 다시 만든 MySQL 객체는 남겨둔 시크릿의 비밀번호를 그대로 이어받는다
+	recreated := &mysqlv1alpha1.MySQL{ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace, UID: "new"}}
+	if err := r.syncRootPasswordSecret(recreated); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.syncSystemUserSecrets(recreated); err != nil {
+		t.Fatal(err)
+	}
+	for secretName, password := range getSecretPasswords(t, r, recreated) {
+		if password != passwords[secretName] {
+			t.Errorf("secret %s has a new password", secretName)
+		}
+	}
+	for _, secretName := range getCredentialSecretNames(recreated) {
+		secret := &corev1.Secret{}
+		if err := r.client.Get(context.TODO(), secretName, secret); err != nil {
+			t.Fatal(err)
+		}
+		if owner := metav1.GetControllerOf(secret); owner == nil || owner.UID != recreated.UID {
+			t.Errorf("secret %s is not adopted by the recreated mysql: %v", secretName.Name, secret.OwnerReferences)
+		}
+	}
+}
+
+func getSecretPasswords(t *testing.T, r *ReconcileMySQL, mysql *mysqlv1alpha1.MySQL) map[string]string {
+	passwords := map[string]string{}
+	for _, secretName := range getCredentialSecretNames(mysql) {
+		secret := &corev1.Secret{}
+		if err := r.client.Get(context.TODO(), secretName, secret); err != nil {
+			t.Fatal(err)
+		}
+		passwords[secretName.Name] = string(secret.Data[passwordKey])
+	}
+	return passwords
+}
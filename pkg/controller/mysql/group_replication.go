@@ -0,0 +1,357 @@
+package mysql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// 그룹 복제를 사용할 수 없는 경우의 컨디션 원인(reason)
+const (
+	reasonUnsupportedTopology status.ConditionReason = "UnsupportedTopology"
+)
+
+// failoverReasonGroupElection 은 그룹이 새로운 프라이머리를 선출해서 프라이머리가 바뀐 경우이다
+const failoverReasonGroupElection = "GroupElection"
+
+const (
+	// groupReplicationPort 는 그룹의 멤버끼리 통신하는 포트이다
+	groupReplicationPort = 33061
+	// groupReplicationMaxMembers 는 하나의 그룹에 참여할 수 있는 최대 멤버의 수이다
+	groupReplicationMaxMembers = 9
+	// groupReplicationCheckInterval 은 그룹의 멤버와 프라이머리를 다시 확인하기까지 기다리는 시간이다
+	// 그룹이 프라이머리를 다시 선출해도 쿠버네티스 객체는 바뀌지 않으므로 주기적으로 조정 루프에 진입해서 확인한다
+	groupReplicationCheckInterval = 30 * time.Second
+)
+
+// performance_schema.replication_group_members 의 MEMBER_STATE 와 MEMBER_ROLE
+const (
+	groupMemberOnline     = "ONLINE"
+	groupMemberRecovering = "RECOVERING"
+	groupMemberOffline    = "OFFLINE"
+	groupMemberError      = "ERROR"
+	groupMemberPrimary    = "PRIMARY"
+)
+
+// getGroupReplicationConfig 는 그룹 복제를 사용할 때 모든 멤버에 적용하는 설정을 리턴한다
+// 그룹은 오퍼레이터가 시작하므로 mysqld 가 시작될 때 그룹에 참여하지 않는다. 새로운 멤버는 그룹의 분산 복구(distributed recovery)로
+// 데이터를 받으며, 필요한 바이너리 로그가 지워졌다면 clone 플러그인으로 전체 데이터를 받는다
+func getGroupReplicationConfig(mysql *mysqlv1alpha1.MySQL) mysqlv1alpha1.MySQLConfig {
+	if mysql.Spec.GetTopology() != mysqlv1alpha1.TopologyGroupReplication {
+		return nil
+	}
+	return mysqlv1alpha1.MySQLConfig{
+		"mysqld": {
+			"binlog-checksum":                           "NONE",
+			"plugin-load-add":                           "group_replication.so;mysql_clone.so",
+			"group-replication-group-name":              string(mysql.UID),
+			"group-replication-recovery-get-public-key": "ON",
+			"group-replication-single-primary-mode":     "ON",
+			"group-replication-start-on-boot":           "OFF",
+		},
+	}
+}
+
+// applyGroupReplication 은 그룹 복제를 사용할 때 파드 스펙을 그룹 복제에 맞게 바꾼다
+// 새로운 멤버는 분산 복구로 데이터를 받으므로 clone-mysql 초기화 컨테이너를 사용하지 않고,
+// 다른 멤버가 분산 복구 때 접속할 수 있도록 멤버의 DNS 이름을 그룹에 알린다
+func applyGroupReplication(mysql *mysqlv1alpha1.MySQL, spec *corev1.PodSpec) {
+	if mysql.Spec.GetTopology() != mysqlv1alpha1.TopologyGroupReplication {
+		return
+	}
+	initContainers := spec.InitContainers[:0]
+	for _, container := range spec.InitContainers {
+		switch container.Name {
+		case "clone-mysql":
+			continue
+		case "init-mysql":
+			container.Command[len(container.Command)-1] += `
+# Report the DNS name of this member to the group for distributed recovery.
+echo report-host=$(hostname).${PEER_DOMAIN} >> /mnt/conf.d/server-id.cnf`
+			container.Env = append(container.Env, newPeerEnv(mysql)...)
+		}
+		initContainers = append(initContainers, container)
+	}
+	spec.InitContainers = initContainers
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == mysqlContainerName {
+			spec.Containers[i].Ports = append(spec.Containers[i].Ports, corev1.ContainerPort{
+				Name:          "group",
+				ContainerPort: groupReplicationPort,
+			})
+		}
+	}
+}
+
+// validateTopology 는 스펙의 복제 구조를 반영할 수 있는지 확인한다
+func validateTopology(mysql *mysqlv1alpha1.MySQL) error {
+	topology := mysql.Spec.GetTopology()
+	if mysql.Status.Topology != "" && topology != mysql.Status.Topology {
+		return &specError{reason: reasonUnsupportedTopology,
+			message: fmt.Sprintf("changing topology from %s to %s is not supported", mysql.Status.Topology, topology)}
+	}
+	if topology != mysqlv1alpha1.TopologyGroupReplication {
+		return nil
+	}
+	if version := mysql.Spec.GetVersion(); version != "8.0" {
+		return &specError{reason: reasonUnsupportedTopology,
+			message: fmt.Sprintf("group replication is not supported on mysql %s", version)}
+	}
+	if mysql.Spec.GetReplicationMode() != mysqlv1alpha1.ReplicationModeGTID {
+		return &specError{reason: reasonUnsupportedTopology, message: "group replication requires gtid replication mode"}
+	}
+	if mysql.Spec.IsSemiSyncEnabled() {
+		return &specError{reason: reasonUnsupportedTopology,
+			message: "semi-synchronous replication cannot be used with group replication"}
+	}
+	if replicas := mysql.Spec.GetReplicas(); replicas > groupReplicationMaxMembers {
+		return &specError{reason: reasonUnsupportedTopology,
+			message: fmt.Sprintf("group replication supports at most %d members, but %d are requested", groupReplicationMaxMembers, replicas)}
+	}
+	return nil
+}
+
+// syncGroupReplication 은 그룹이 없으면 만들고(bootstrap), 그룹에 참여하지 않은 멤버를 그룹에 참여시킨다
+// 시스템 계정은 프라이머리에만 만들고 분산 복구로 다른 멤버에 전달되므로, 새로운 멤버는 그룹에 참여하기 전까지 모니터링 계정이 없어서 준비되지 않는다.
+// 그래서 준비된 멤버가 아니라 mysqld 가 실행 중인 멤버를 그룹에 참여시킨다
+// 프라이머리는 그룹이 선출하므로 선출된 멤버를 상태에 기록하고, 스펙에 프라이머리를 지정했다면 그룹에 프라이머리를 바꾸도록 요청한다
+func (r *ReconcileMySQL) syncGroupReplication(mysql *mysqlv1alpha1.MySQL) (reconcile.Result, error) {
+	klog.Infof("[%s] syncGroupReplication", mysql.Name)
+	pods, err := r.listPods(mysql)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	var ready, running []string
+	isReady := make(map[string]bool, len(pods))
+	for i := range pods {
+		if !isContainerRunning(&pods[i], mysqlContainerName) || isDepartingMember(mysql, &pods[i]) {
+			continue
+		}
+		running = append(running, pods[i].Name)
+		if isPodReady(&pods[i]) {
+			ready = append(ready, pods[i].Name)
+			isReady[pods[i].Name] = true
+		}
+	}
+	sort.Strings(ready)
+	sort.Strings(running)
+
+	members := make(map[string]mysqlv1alpha1.GroupMemberStatus, len(running))
+	var online []string
+	for _, name := range running {
+		member, err := r.getGroupMember(mysql, name)
+		if err != nil {
+			// 준비되지 않은 멤버는 mysqld 가 아직 초기화 중일 수 있으므로 다음 조정 루프에서 다시 확인한다
+			if !isReady[name] {
+				klog.Infof("[%s] Could not get group member state of %s yet: %v", mysql.Name, name, err)
+				continue
+			}
+			return reconcile.Result{}, err
+		}
+		members[name] = member
+		if member.State == groupMemberOnline || member.State == groupMemberRecovering {
+			online = append(online, name)
+		}
+	}
+
+	// 그룹에 참여한 멤버가 없다면 그룹을 만든다
+	if len(online) == 0 {
+		seed, err := r.getBootstrapMember(mysql, ready, len(pods))
+		if err != nil || seed == "" {
+			return reconcile.Result{RequeueAfter: groupReplicationCheckInterval}, err
+		}
+		klog.Infof("[%s] Bootstrap group replication on %s", mysql.Name, seed)
+		if err := r.startGroupReplication(mysql, seed, true); err != nil {
+			return reconcile.Result{}, err
+		}
+		members[seed] = mysqlv1alpha1.GroupMemberStatus{Name: seed, State: groupMemberOnline, Role: groupMemberPrimary}
+	}
+
+	// 그룹에서 빠진 멤버는 다시 참여시킨다. 에러 상태인 멤버는 그룹 복제를 멈춘 다음 다시 시작한다
+	for _, name := range running {
+		member, ok := members[name]
+		if !ok || member.State != groupMemberOffline && member.State != groupMemberError {
+			continue
+		}
+		klog.Infof("[%s] Join %s to group replication", mysql.Name, name)
+		if err := r.startGroupReplication(mysql, name, false); err != nil {
+			klog.Errorf("[%s] Could not join %s to group replication: %v", mysql.Name, name, err)
+		}
+	}
+
+	// 그룹이 선출한 프라이머리를 기록한다
+	primaryName := getPrimaryPodName(mysql)
+	for _, name := range running {
+		member := members[name]
+		if member.State != groupMemberOnline || member.Role != groupMemberPrimary || name == primaryName {
+			continue
+		}
+		return reconcile.Result{RequeueAfter: groupReplicationCheckInterval}, r.recordFailover(mysql, &mysqlv1alpha1.FailoverRecord{
+			Time:       metav1.Now(),
+			OldPrimary: primaryName,
+			NewPrimary: name,
+			Reason:     failoverReasonGroupElection,
+		})
+	}
+
+	// 스펙에 지정한 프라이머리가 그룹에 참여하고 있으면 그룹에 프라이머리를 바꾸도록 요청한다
+	if target := getSwitchoverTarget(mysql); target != "" && target != primaryName && members[primaryName].State == groupMemberOnline {
+		if members[target].State != groupMemberOnline {
+			klog.Infof("[%s] Waiting for %s to be online in the group to switch primary", mysql.Name, target)
+			return reconcile.Result{RequeueAfter: groupReplicationCheckInterval}, nil
+		}
+		klog.Infof("[%s] Switch primary of the group from %s to %s", mysql.Name, primaryName, target)
+		rows, err := r.runSQL(mysql, target, "SELECT @@global.server_uuid AS server_uuid")
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if len(rows) == 0 {
+			return reconcile.Result{}, fmt.Errorf("could not get server uuid of %s", target)
+		}
+		if _, err := r.runSQL(mysql, primaryName,
+			fmt.Sprintf("SELECT group_replication_set_as_primary(%s)", quoteSQL(rows[0]["server_uuid"]))); err != nil {
+			return reconcile.Result{}, err
+		}
+		return reconcile.Result{RequeueAfter: groupReplicationCheckInterval}, r.recordFailover(mysql, &mysqlv1alpha1.FailoverRecord{
+			Time:       metav1.Now(),
+			OldPrimary: primaryName,
+			NewPrimary: target,
+			Reason:     failoverReasonSwitchover,
+		})
+	}
+	return reconcile.Result{RequeueAfter: groupReplicationCheckInterval}, nil
+}
+
+// getBootstrapMember 는 그룹을 만들 멤버를 리턴한다. 그룹을 만들 수 있는 멤버가 아직 없다면 빈 문자열을 리턴한다
+// 처음 만드는 클러스터는 0번 멤버에서 그룹을 만든다. 모든 멤버가 멈췄던 클러스터는 데이터를 잃지 않도록 모든 멤버가 준비될 때까지 기다린 다음,
+// 다른 모든 멤버의 트랜잭션을 가진 멤버에서 그룹을 만든다
+func (r *ReconcileMySQL) getBootstrapMember(mysql *mysqlv1alpha1.MySQL, ready []string, total int) (string, error) {
+	if mysql.Status.Phase == "" || mysql.Status.Phase == mysqlv1alpha1.MySQLPhaseCreating {
+		first := getPodName(mysql, 0)
+		for _, name := range ready {
+			if name == first {
+				return first, nil
+			}
+		}
+		return "", nil
+	}
+	if len(ready) < total || int32(len(ready)) < mysql.Spec.GetReplicas() {
+		klog.Infof("[%s] Waiting for all members to be ready to recover group replication (%d/%d)", mysql.Name, len(ready), total)
+		return "", nil
+	}
+
+	executed := make(map[string]string, len(ready))
+	for _, name := range ready {
+		// batch 모드는 값의 줄바꿈을 이스케이프하므로 GTID 집합의 줄바꿈을 지우고 가져온다
+		rows, err := r.runSQL(mysql, name, `SELECT REPLACE(@@global.gtid_executed, '\n', '') AS gtid_executed`)
+		if err != nil {
+			return "", err
+		}
+		if len(rows) == 0 {
+			return "", fmt.Errorf("could not get executed GTID set of %s", name)
+		}
+		executed[name] = rows[0]["gtid_executed"]
+	}
+	for _, candidate := range ready {
+		superset := true
+		for _, name := range ready {
+			if name == candidate {
+				continue
+			}
+			rows, err := r.runSQL(mysql, candidate,
+				fmt.Sprintf("SELECT GTID_SUBSET(%s, @@global.gtid_executed) AS subset", quoteSQL(executed[name])))
+			if err != nil {
+				return "", err
+			}
+			if len(rows) == 0 || rows[0]["subset"] != "1" {
+				superset = false
+				break
+			}
+		}
+		if superset {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("could not recover group replication: no member has the transactions of all other members")
+}
+
+// startGroupReplication 은 멤버가 그룹에 참여하도록 그룹 복제를 시작한다. bootstrap 이 true 이면 멤버 혼자 새로운 그룹을 만든다
+// 멤버의 주소와 다른 멤버의 주소(seed)는 멤버의 수가 바뀌어도 멤버가 다시 시작되지 않도록 설정 파일 대신 시작하기 전에 지정한다
+func (r *ReconcileMySQL) startGroupReplication(mysql *mysqlv1alpha1.MySQL, podName string, bootstrap bool) error {
+	password, err := r.getSystemUserPassword(mysql, replicationUser)
+	if err != nil {
+		return err
+	}
+	seeds := make([]string, 0, mysql.Spec.GetReplicas())
+	for i := 0; i < int(mysql.Spec.GetReplicas()); i++ {
+		seeds = append(seeds, fmt.Sprintf("%s:%d", getPeerHost(mysql, i), groupReplicationPort))
+	}
+	queries := []string{
+		"STOP GROUP_REPLICATION",
+		fmt.Sprintf("SET GLOBAL group_replication_local_address = %s",
+			quoteSQL(fmt.Sprintf("%s:%d", getMemberHost(mysql, podName), groupReplicationPort))),
+		fmt.Sprintf("SET GLOBAL group_replication_group_seeds = %s", quoteSQL(strings.Join(seeds, ","))),
+		fmt.Sprintf("CHANGE MASTER TO MASTER_USER=%s, MASTER_PASSWORD=%s FOR CHANNEL 'group_replication_recovery'",
+			quoteSQL(replicationUser.name), quoteSQL(password)),
+	}
+	if bootstrap {
+		queries = append(queries, "SET GLOBAL group_replication_bootstrap_group = ON",
+			"START GROUP_REPLICATION", "SET GLOBAL group_replication_bootstrap_group = OFF")
+	} else {
+		queries = append(queries, "START GROUP_REPLICATION")
+	}
+	_, err = r.runSQL(mysql, podName, strings.Join(queries, "; "))
+	return err
+}
+
+// getGroupMember 는 멤버가 스스로 보는 그룹 안에서의 상태와 역할을 가져온다
+// 그룹 복제를 시작하지 않은 멤버는 replication_group_members 에 자신이 없으므로 OFFLINE 이다
+func (r *ReconcileMySQL) getGroupMember(mysql *mysqlv1alpha1.MySQL, podName string) (mysqlv1alpha1.GroupMemberStatus, error) {
+	member := mysqlv1alpha1.GroupMemberStatus{Name: podName, State: groupMemberOffline}
+	rows, err := r.runSQL(mysql, podName, "SELECT MEMBER_STATE, MEMBER_ROLE FROM performance_schema.replication_group_members "+
+		"WHERE MEMBER_ID = @@global.server_uuid")
+	if err != nil {
+		return member, err
+	}
+	if len(rows) > 0 && rows[0]["MEMBER_STATE"] != "" {
+		member.State = rows[0]["MEMBER_STATE"]
+		member.Role = rows[0]["MEMBER_ROLE"]
+	}
+	return member, nil
+}
+
+// observeGroupReplication 은 각 멤버의 그룹 안에서의 상태와 역할로 GroupMembers 를 채운다
+// 준비되지 않았거나 접속할 수 없는 멤버는 OFFLINE 으로 기록한다
+func (r *ReconcileMySQL) observeGroupReplication(mysql *mysqlv1alpha1.MySQL, newStatus *mysqlv1alpha1.MySQLStatus, pods []corev1.Pod) {
+	if mysql.Spec.GetTopology() != mysqlv1alpha1.TopologyGroupReplication {
+		newStatus.GroupMembers = nil
+		return
+	}
+	podByName := make(map[string]*corev1.Pod, len(pods))
+	for i := range pods {
+		podByName[pods[i].Name] = &pods[i]
+	}
+	members := make([]mysqlv1alpha1.GroupMemberStatus, 0, mysql.Spec.GetReplicas())
+	for i := 0; i < int(mysql.Spec.GetReplicas()); i++ {
+		name := getPodName(mysql, i)
+		member := mysqlv1alpha1.GroupMemberStatus{Name: name, State: groupMemberOffline}
+		if pod, ok := podByName[name]; ok && isPodReady(pod) {
+			observed, err := r.getGroupMember(mysql, name)
+			if err != nil {
+				klog.Errorf("[%s] Could not get group replication state of %s: %v", mysql.Name, name, err)
+			} else {
+				member = observed
+			}
+		}
+		members = append(members, member)
+	}
+	newStatus.GroupMembers = members
+}
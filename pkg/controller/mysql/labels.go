@@ -0,0 +1,89 @@
+package mysql
+
+import (
+	"strconv"
+
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// 오퍼레이터가 관리하는 객체에 붙이는 레이블의 키와 값
+// https://kubernetes.io/docs/concepts/overview/working-with-objects/common-labels/
+const (
+	labelName      = "app.kubernetes.io/name"
+	labelInstance  = "app.kubernetes.io/instance"
+	labelManagedBy = "app.kubernetes.io/managed-by"
+	labelComponent = "app.kubernetes.io/component"
+
+	appName      = "mysql"
+	operatorName = "sample-mysql-operator"
+
+	componentDatabase    = "database"
+	componentService     = "service"
+	componentConfig      = "config"
+	componentCredentials = "credentials"
+)
+
+// labelRole 은 멤버 파드의 현재 역할을 나타내는 레이블이다. 쓰기용 서비스는 이 레이블로 프라이머리를 고른다
+// labelReadable 은 멤버 파드가 읽기를 받을 수 있는지 나타내는 레이블이다. 읽기용 서비스는 이 레이블이 true 인 파드를 고른다
+const (
+	labelRole     = "role"
+	labelReadable = "readable"
+
+	rolePrimary = "primary"
+	roleReplica = "replica"
+)
+
+// selectorForMySQL 는 mysql 객체가 소유한 파드를 고르기 위한 셀렉터를 리턴한다
+// 인스턴스 레이블을 포함하기 때문에 같은 네임스페이스에 여러 MySQL 이 있어도 서로의 파드를 고르지 않는다
+// 스테이트풀셋의 셀렉터는 바꿀 수 없으므로 이 값은 절대 변경해서는 안 된다
+func selectorForMySQL(mysql *mysqlv1alpha1.MySQL) map[string]string {
+	return map[string]string{
+		labelName:     appName,
+		labelInstance: mysql.Name,
+	}
+}
+
+// labelsForMySQL 는 mysql 객체가 소유한 객체에 붙일 레이블을 리턴한다. component 는 객체의 역할을 나타낸다
+func labelsForMySQL(mysql *mysqlv1alpha1.MySQL, component string) map[string]string {
+	labels := selectorForMySQL(mysql)
+	labels[labelManagedBy] = operatorName
+	labels[labelComponent] = component
+	return labels
+}
+
+// generationAnnotation 는 오퍼레이터가 관리하는 객체가 mysql 객체의 몇 번째 세대(generation)의 스펙으로 만들어졌는지 기록하는 어노테이션이다
+const generationAnnotation = "mysql.woohhan.com/generation"
+
+// annotationsForMySQL 는 mysql 객체가 소유한 객체에 붙일 어노테이션을 리턴한다
+func annotationsForMySQL(mysql *mysqlv1alpha1.MySQL) map[string]string {
+	return map[string]string{
+		generationAnnotation: strconv.FormatInt(mysql.Generation, 10),
+	}
+}
+
+// mergeMetadata 는 desired 의 레이블과 어노테이션을 live 에 덮어쓰고 변경이 있었는지 리턴한다
+// 다른 도구가 추가한 레이블과 어노테이션은 그대로 둔다
+func mergeMetadata(live, desired *metav1.ObjectMeta) bool {
+	changed := false
+	if !equality.Semantic.DeepDerivative(desired.Labels, live.Labels) {
+		if live.Labels == nil {
+			live.Labels = map[string]string{}
+		}
+		for key, value := range desired.Labels {
+			live.Labels[key] = value
+		}
+		changed = true
+	}
+	if !equality.Semantic.DeepDerivative(desired.Annotations, live.Annotations) {
+		if live.Annotations == nil {
+			live.Annotations = map[string]string{}
+		}
+		for key, value := range desired.Annotations {
+			live.Annotations[key] = value
+		}
+		changed = true
+	}
+	return changed
+}
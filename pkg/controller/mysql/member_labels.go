@@ -0,0 +1,50 @@
+package mysql
+
+import (
+	"context"
+	"strconv"
+
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// syncMemberLabels 는 각 멤버 파드에 현재 역할과 읽기를 받을 수 있는지를 나타내는 레이블을 붙인다
+// 프라이머리는 페일오버나 그룹의 선출로 바뀔 수 있고 복제 상태는 계속 바뀌므로, 스테이트풀셋의 파드 템플릿 대신 오퍼레이터가 파드마다 직접 붙인다
+// 파드의 다른 필드는 kubelet 과 스테이트풀셋 컨트롤러도 바꾸므로 레이블만 머지 패치하며, 한 파드에 실패해도 나머지 파드의 레이블은 붙인다
+func (r *ReconcileMySQL) syncMemberLabels(mysql *mysqlv1alpha1.MySQL) error {
+	klog.Infof("[%s] syncMemberLabels", mysql.Name)
+	pods, err := r.listPods(mysql)
+	if err != nil {
+		return err
+	}
+	primaryName := getPrimaryPodName(mysql)
+	readable := r.getReadableMembers(mysql, pods)
+	var lastErr error
+	for i := range pods {
+		pod := &pods[i]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		role := roleReplica
+		if pod.Name == primaryName {
+			role = rolePrimary
+		}
+		readableValue := strconv.FormatBool(readable[pod.Name])
+		if pod.Labels[labelRole] == role && pod.Labels[labelReadable] == readableValue {
+			continue
+		}
+		klog.Infof("[%s] Label %s as %s (readable: %s)", mysql.Name, pod.Name, role, readableValue)
+		patch := client.MergeFrom(pod.DeepCopy())
+		if pod.Labels == nil {
+			pod.Labels = map[string]string{}
+		}
+		pod.Labels[labelRole] = role
+		pod.Labels[labelReadable] = readableValue
+		if err := r.client.Patch(context.TODO(), pod, patch); err != nil {
+			klog.Errorf("[%s] Could not label %s: %v", mysql.Name, pod.Name, err)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
@@ -0,0 +1,58 @@
+package mysql
+
+import (
+	"context"
+	"testing"
+
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSyncMemberLabels(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := mysqlv1alpha1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	mysql := &mysqlv1alpha1.MySQL{
+		ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: "default"},
+		Status:     mysqlv1alpha1.MySQLStatus{CurrentPrimary: "mysql-1"},
+	}
+	var objects []runtime.Object
+	for _, name := range []string{"mysql-0", "mysql-1"} {
+		labels := selectorForMySQL(mysql)
+		labels["extra"] = name
+		labels[labelRole] = roleReplica
+		if name == "mysql-0" {
+			// 페일오버 전의 레이블이 남아 있다
+			labels[labelRole] = rolePrimary
+		}
+		objects = append(objects, &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: mysql.Namespace, Labels: labels}})
+	}
+	r := &ReconcileMySQL{client: fake.NewFakeClientWithScheme(scheme, objects...), scheme: scheme}
+
+	// 준비된 레플리카가 없어서 복제 상태를 확인하지 못해도 현재 프라이머리에 레이블을 붙인다
+	if err := r.syncMemberLabels(mysql); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"mysql-0": roleReplica, "mysql-1": rolePrimary}
+	for name, role := range want {
+		pod := &corev1.Pod{}
+		if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: name}, pod); err != nil {
+			t.Fatal(err)
+		}
+		if pod.Labels[labelRole] != role {
+			t.Errorf("%s has role %q, want %q", name, pod.Labels[labelRole], role)
+		}
+		// 오퍼레이터가 관리하지 않는 레이블은 그대로 남는다
+		if pod.Labels["extra"] != name {
+			t.Errorf("%s lost its other labels: %v", name, pod.Labels)
+		}
+	}
+}
@@ -2,17 +2,22 @@ package mysql
 
 import (
 	"context"
+	"sync"
+
 	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
 	v1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/klog"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
@@ -23,7 +28,12 @@ func Add(mgr manager.Manager) error {
 }
 
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcileMySQL{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	return &ReconcileMySQL{
+		client:    mgr.GetClient(),
+		scheme:    mgr.GetScheme(),
+		config:    mgr.GetConfig(),
+		clientset: kubernetes.NewForConfigOrDie(mgr.GetConfig()),
+	}
 }
 
 func add(mgr manager.Manager, r reconcile.Reconciler) error {
@@ -32,7 +42,10 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 	// 프라이머리 오브젝트 (MySQL)에 변경이 있으면 조정루프에 진입한다.
-	if err := c.Watch(&source.Kind{Type: &mysqlv1alpha1.MySQL{}}, &handler.EnqueueRequestForObject{}); err != nil {
+	// 조정 루프가 매번 복제 지연 같은 관찰 결과를 상태에 기록하므로, 상태만 바뀐 경우에는 진입하지 않도록 세대가 바뀐 경우만 고른다
+	// 삭제가 시작되면 API 서버가 세대를 올리므로 삭제 정책의 적용은 그대로 시작된다
+	if err := c.Watch(&source.Kind{Type: &mysqlv1alpha1.MySQL{}}, &handler.EnqueueRequestForObject{},
+		predicate.GenerationChangedPredicate{}); err != nil {
 		return err
 	}
 	// 세컨더리 오브젝트 중 서비스에 변경이 있으면 조정 루프에 진입한다
@@ -40,11 +53,32 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		&handler.EnqueueRequestForOwner{IsController: true, OwnerType: &mysqlv1alpha1.MySQL{}}); err != nil {
 		return err
 	}
+	// 세컨더리 오브젝트 중 컨피그맵에 변경이 있으면 조정 루프에 진입한다
+	if err := c.Watch(&source.Kind{Type: &corev1.ConfigMap{}},
+		&handler.EnqueueRequestForOwner{IsController: true, OwnerType: &mysqlv1alpha1.MySQL{}}); err != nil {
+		return err
+	}
+	// 세컨더리 오브젝트 중 시크릿에 변경이 있으면 조정 루프에 진입한다
+	if err := c.Watch(&source.Kind{Type: &corev1.Secret{}},
+		&handler.EnqueueRequestForOwner{IsController: true, OwnerType: &mysqlv1alpha1.MySQL{}}); err != nil {
+		return err
+	}
 	// 세컨더리 오브젝트 중 스테이트풀셋에 변경이 있으면 조정 루프에 진입한다
 	if err := c.Watch(&source.Kind{Type: &v1.StatefulSet{}},
 		&handler.EnqueueRequestForOwner{IsController: true, OwnerType: &mysqlv1alpha1.MySQL{}}); err != nil {
 		return err
 	}
+	// 데이터 볼륨 클레임은 스테이트풀셋이 만들기 때문에 오너가 없다. 셀렉터 레이블로 MySQL 객체를 찾아서 조정 루프에 진입한다
+	// 볼륨을 늘리는 중에 볼륨 클레임의 용량이 바뀌면 다음 단계를 진행하기 위해 필요하다
+	if err := c.Watch(&source.Kind{Type: &corev1.PersistentVolumeClaim{}},
+		&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(requestsForDataVolumeClaim)}); err != nil {
+		return err
+	}
+	// 세컨더리 오브젝트 중 파드 중단 예산에 변경이 있으면 조정 루프에 진입한다
+	if err := c.Watch(&source.Kind{Type: &policyv1beta1.PodDisruptionBudget{}},
+		&handler.EnqueueRequestForOwner{IsController: true, OwnerType: &mysqlv1alpha1.MySQL{}}); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -56,6 +90,15 @@ type ReconcileMySQL struct {
 	// This client, initialized using mgr.Client() above, is a split client that reads objects from the cache and writes to the apiserver
 	client client.Client
 	scheme *runtime.Scheme
+	// config 와 clientset 은 멤버 파드에서 명령을 실행(exec)할 때 사용한다. 컨트롤러 런타임의 클라이언트는 서브리소스 스트림을 지원하지 않는다
+	config    *rest.Config
+	clientset kubernetes.Interface
+	// appliedSystemUsers 는 MySQL 객체의 UID 별로 프라이머리에 마지막으로 적용한 시스템 계정의 해시를 가진다
+	appliedSystemUsers sync.Map
+	// failoverStartedAt 은 MySQL 객체의 UID 별로 페일오버를 시작해서 레플리카의 IO 스레드를 멈춘 시점을 가진다
+	failoverStartedAt sync.Map
+	// switchoverStartedAt 은 MySQL 객체의 UID 별로 스위치오버를 시작해서 이전 프라이머리의 쓰기를 막은 시점을 가진다
+	switchoverStartedAt sync.Map
 }
 
 // Reconcile 는 클러스터로부터 MySQL 객체를 읽어와서 MySQL.Spec과 실제 클러스터의 상태를 비교해서 싱크를 맞춘다
@@ -70,31 +113,103 @@ func (r *ReconcileMySQL) Reconcile(request reconcile.Request) (reconcile.Result,
 	if err := r.client.Get(context.TODO(), request.NamespacedName, mysql); err != nil {
 		if errors.IsNotFound(err) {
 			// 인스턴스가 없는 것은 인스턴스가 삭제된 직후에 조정루프에 들어온 경우이다.
-			// 별다른 처리 없이 바로 리턴한다. 만약 추가적인 삭제 작업이 필요하다면 파이널라이즈를 사용해야 한다.
+			// 별다른 처리 없이 바로 리턴한다. 데이터 볼륨 클레임의 정리는 파이널라이저를 이용해서 삭제 전에 처리한다.
 			return reconcile.Result{}, nil
 		}
 		return reconcile.Result{}, err
 	}
 
-	// MySQL을 위한 컨피그맵이 존재하는지 확인한다
-	if err := r.checkConfigMap(mysql); err != nil {
+	// 삭제 중인 MySQL 객체에는 스펙을 반영하지 않고 삭제 정책만 적용한다
+	if mysql.DeletionTimestamp != nil {
+		return r.reconcileDeletion(mysql)
+	}
+
+	// 반영할 수 없는 스펙이면 상태에 기록만 하고 스펙이 바뀔 때까지 다시 시도하지 않는다
+	if err := validateSpec(mysql); err != nil {
+		klog.Errorf("[%s] Invalid mysql spec: %v", request.NamespacedName, err)
+		return reconcile.Result{}, r.updateStatus(mysql, err)
+	}
+
+	// 스펙을 클러스터에 반영하고, 그 결과와 관찰한 클러스터의 상태를 MySQL 객체의 상태에 기록한다
+	result, syncErr := r.sync(mysql)
+	if err := r.updateStatus(mysql, syncErr); err != nil {
 		return reconcile.Result{}, err
 	}
+	// 스펙이 잘못되어 반영하지 못한 경우도 스펙이 바뀔 때까지 다시 시도하지 않는다
+	if _, ok := syncErr.(*specError); ok {
+		klog.Errorf("[%s] Could not apply mysql spec: %v", request.NamespacedName, syncErr)
+		return reconcile.Result{}, nil
+	}
+	// 다른 곳에서 먼저 객체를 바꾼 충돌은 캐시가 갱신된 뒤에 다시 시도하면 해결되므로 에러로 다루지 않는다
+	if errors.IsConflict(syncErr) {
+		klog.Infof("[%s] Conflict while applying mysql spec. Retry: %v", request.NamespacedName, syncErr)
+		return reconcile.Result{Requeue: true}, nil
+	}
+	return result, syncErr
+}
 
-	// MySQL 커스텀 리소스가 관리할 각각의 객체에 대해 조정루프를 실행해서 싱크를 맞춘다
+// sync 는 MySQL 커스텀 리소스가 관리할 각각의 객체에 대해 조정루프를 실행해서 싱크를 맞춘다
+// 리턴하는 결과는 객체의 변경과 관계없이 다시 조정 루프에 진입해야 하는 시점이다
+func (r *ReconcileMySQL) sync(mysql *mysqlv1alpha1.MySQL) (reconcile.Result, error) {
+	// 파이널라이저를 먼저 붙여야 삭제할 때 데이터 볼륨 클레임과 비밀번호 시크릿을 놓치지 않는다
+	if err := r.syncFinalizer(mysql); err != nil {
+		return reconcile.Result{}, err
+	}
+	// 스테이트풀셋의 파드가 설정과 비밀번호를 사용하므로 컨피그맵과 시크릿을 먼저 맞춘다
+	if err := r.syncConfigMap(mysql); err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := r.syncRootPasswordSecret(mysql); err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := r.syncSystemUserSecrets(mysql); err != nil {
+		return reconcile.Result{}, err
+	}
+	// 바뀐 스크립트와 프로브로 멤버가 다시 시작되기 전에 시스템 계정이 있어야 하므로 스테이트풀셋보다 먼저 맞춘다
+	usersPending, err := r.syncSystemUsers(mysql)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
 	if err := r.syncService(mysql); err != nil {
 		return reconcile.Result{}, err
 	}
 	if err := r.syncReadService(mysql); err != nil {
 		return reconcile.Result{}, err
 	}
+	if err := r.syncPrimaryService(mysql); err != nil {
+		return reconcile.Result{}, err
+	}
 	if err := r.syncStatefulSet(mysql); err != nil {
 		return reconcile.Result{}, err
 	}
-	return reconcile.Result{}, nil
-}
-
-func (r *ReconcileMySQL) checkConfigMap(mysql *mysqlv1alpha1.MySQL) error {
-	config := &corev1.ConfigMap{}
-	return r.client.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: mysql.Name}, config)
+	if err := r.syncDepartedDataVolumeClaims(mysql); err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := r.syncPodDisruptionBudget(mysql); err != nil {
+		return reconcile.Result{}, err
+	}
+	// 프라이머리의 장애를 확인하는 동안에는 정해진 시간 뒤에 다시 조정 루프에 진입해야 하므로 결과를 리턴한다
+	// 그룹 복제는 그룹이 프라이머리를 선출하므로 오퍼레이터는 그룹을 만들고 멤버를 참여시키기만 한다
+	var result reconcile.Result
+	if mysql.Spec.GetTopology() == mysqlv1alpha1.TopologyGroupReplication {
+		result, err = r.syncGroupReplication(mysql)
+	} else {
+		result, err = r.syncPrimary(mysql)
+	}
+	// 프라이머리가 바뀌었을 수 있으므로 프라이머리를 확인한 다음에 멤버의 레이블을 붙인다
+	// 레플리카의 역할이나 복제를 맞추지 못했더라도 쓰기용 서비스가 현재 프라이머리를 가리키도록 레이블은 붙인다
+	if labelErr := r.syncMemberLabels(mysql); labelErr != nil && err == nil {
+		err = labelErr
+	}
+	if err != nil {
+		return result, err
+	}
+	// 레플리카의 복제 상태를 주기적으로 확인해서 멈춘 복제를 다시 시작하고, 상태와 읽기용 서비스가 고를 멤버를 갱신한다
+	if mysql.Spec.GetReplicas() > 1 && (result.RequeueAfter == 0 || result.RequeueAfter > replicationCheckInterval) {
+		result.RequeueAfter = replicationCheckInterval
+	}
+	if usersPending && (result.RequeueAfter == 0 || result.RequeueAfter > systemUsersRetryInterval) {
+		result.RequeueAfter = systemUsersRetryInterval
+	}
+	return result, nil
 }
@@ -0,0 +1,116 @@
+package mysql
+
+import (
+	"context"
+
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// syncPodDisruptionBudget 는 mysql 파드 중단 예산(PDB)이 없는 경우 생성하고, 있는 경우 스펙과 다른 부분을 갱신한다
+// 노드를 드레인할 때 여러 멤버가 한 번에 축출되지 않도록 한다
+func (r *ReconcileMySQL) syncPodDisruptionBudget(mysql *mysqlv1alpha1.MySQL) error {
+	klog.Infof("[%s] syncPodDisruptionBudget", mysql.Name)
+	// 클러스터로부터 파드 중단 예산을 가져온다
+	pdb := &policyv1beta1.PodDisruptionBudget{}
+	if err := r.client.Get(context.TODO(), getPodDisruptionBudgetName(mysql), pdb); err != nil {
+		// Not Found 에러가 아닌 경우는 가져오는데 실패한 것이므로 에러를 바로 리턴한다
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		// 파드 중단 예산이 없으므로 생성한다
+		klog.Infof("[%s] Could not find mysql pod disruption budget. Create a new one", mysql.Name)
+		return r.createPodDisruptionBudget(mysql)
+	}
+	// 원하는 파드 중단 예산 객체를 만들어서 클러스터의 파드 중단 예산과 비교하고, 다르면 갱신한다
+	desired, err := newPodDisruptionBudget(mysql, r.scheme)
+	if err != nil {
+		return err
+	}
+	if !updatePodDisruptionBudget(pdb, desired) {
+		return nil
+	}
+	klog.Infof("[%s] Update mysql pod disruption budget", mysql.Name)
+	return r.client.Update(context.TODO(), pdb)
+}
+
+// getPodDisruptionBudgetName 는 mysql 파드 중단 예산의 이름과 네임스페이스를 리턴한다
+func getPodDisruptionBudgetName(mysql *mysqlv1alpha1.MySQL) types.NamespacedName {
+	return types.NamespacedName{Namespace: mysql.Namespace, Name: mysql.Name}
+}
+
+// createPodDisruptionBudget 는 새로운 파드 중단 예산을 생성한다. 이미 존재하는 경우 성공한다
+func (r *ReconcileMySQL) createPodDisruptionBudget(mysql *mysqlv1alpha1.MySQL) error {
+	pdb, err := newPodDisruptionBudget(mysql, r.scheme)
+	if err != nil {
+		return err
+	}
+	if err := r.client.Create(context.TODO(), pdb); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// newPodDisruptionBudget 는 파드 중단 예산을 위한 객체를 생성한다. 객체는 mysql 객체를 오너로 가진다
+// 멤버가 하나라면 쿼럼을 유지할 수 없고 minAvailable 이 1 이면 노드 드레인이 영원히 멈추므로 하나의 멤버를 축출할 수 있도록 maxUnavailable 을 사용한다
+func newPodDisruptionBudget(mysql *mysqlv1alpha1.MySQL, scheme *runtime.Scheme) (*policyv1beta1.PodDisruptionBudget, error) {
+	spec := policyv1beta1.PodDisruptionBudgetSpec{
+		Selector: &v1.LabelSelector{
+			MatchLabels: selectorForMySQL(mysql),
+		},
+	}
+	if replicas := mysql.Spec.GetReplicas(); replicas > 1 {
+		minAvailable := intstr.FromInt(int(getMinAvailable(replicas)))
+		spec.MinAvailable = &minAvailable
+	} else {
+		maxUnavailable := intstr.FromInt(1)
+		spec.MaxUnavailable = &maxUnavailable
+	}
+	pdb := &policyv1beta1.PodDisruptionBudget{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        getPodDisruptionBudgetName(mysql).Name,
+			Namespace:   getPodDisruptionBudgetName(mysql).Namespace,
+			Labels:      labelsForMySQL(mysql, componentDatabase),
+			Annotations: annotationsForMySQL(mysql),
+		},
+		Spec: spec,
+	}
+	if err := controllerutil.SetControllerReference(mysql, pdb, scheme); err != nil {
+		return nil, err
+	}
+	return pdb, nil
+}
+
+// getMinAvailable 는 자발적인 중단 중에도 항상 준비되어 있어야 하는 멤버의 수를 리턴한다
+// 과반수(쿼럼)를 유지하되, 적어도 한 멤버는 축출할 수 있도록 해서 노드 드레인이 멈추지 않게 한다
+func getMinAvailable(replicas int32) int32 {
+	quorum := replicas/2 + 1
+	if quorum > replicas-1 {
+		return replicas - 1
+	}
+	return quorum
+}
+
+// updatePodDisruptionBudget 는 live 파드 중단 예산을 desired 에 맞추고 변경이 있었는지 리턴한다
+func updatePodDisruptionBudget(live, desired *policyv1beta1.PodDisruptionBudget) bool {
+	changed := mergeMetadata(&live.ObjectMeta, &desired.ObjectMeta)
+	if !equality.Semantic.DeepEqual(desired.Spec.MinAvailable, live.Spec.MinAvailable) ||
+		!equality.Semantic.DeepEqual(desired.Spec.MaxUnavailable, live.Spec.MaxUnavailable) {
+		live.Spec.MinAvailable = desired.Spec.MinAvailable
+		live.Spec.MaxUnavailable = desired.Spec.MaxUnavailable
+		changed = true
+	}
+	if !equality.Semantic.DeepEqual(desired.Spec.Selector, live.Spec.Selector) {
+		live.Spec.Selector = desired.Spec.Selector
+		changed = true
+	}
+	return changed
+}
@@ -0,0 +1,70 @@
+package mysql
+
+import (
+	"testing"
+
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestGetMinAvailable(t *testing.T) {
+	tests := []struct {
+		replicas int32
+		want     int32
+	}{
+		{replicas: 2, want: 1},
+		{replicas: 3, want: 2},
+		{replicas: 4, want: 3},
+		{replicas: 5, want: 3},
+	}
+	for _, tt := range tests {
+		if got := getMinAvailable(tt.replicas); got != tt.want {
+			t.Errorf("getMinAvailable(%d) = %d, want %d", tt.replicas, got, tt.want)
+		}
+	}
+}
+
+func TestNewPodDisruptionBudget(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := mysqlv1alpha1.SchemeBuilder.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		replicas       int32
+		minAvailable   *intstr.IntOrString
+		maxUnavailable *intstr.IntOrString
+	}{
+		{replicas: 1, maxUnavailable: intOrStringPtr(1)},
+		{replicas: 2, minAvailable: intOrStringPtr(1)},
+		{replicas: 3, minAvailable: intOrStringPtr(2)},
+	}
+	for _, tt := range tests {
+		replicas := tt.replicas
+		mysql := &mysqlv1alpha1.MySQL{
+			ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: "default"},
+			Spec:       mysqlv1alpha1.MySQLSpec{Replicas: &replicas},
+		}
+		pdb, err := newPodDisruptionBudget(mysql, scheme)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalIntOrString(pdb.Spec.MinAvailable, tt.minAvailable) || !equalIntOrString(pdb.Spec.MaxUnavailable, tt.maxUnavailable) {
+			t.Errorf("replicas %d: minAvailable = %v, maxUnavailable = %v, want %v, %v",
+				tt.replicas, pdb.Spec.MinAvailable, pdb.Spec.MaxUnavailable, tt.minAvailable, tt.maxUnavailable)
+		}
+	}
+}
+
+func intOrStringPtr(value int) *intstr.IntOrString {
+	v := intstr.FromInt(value)
+	return &v
+}
+
+func equalIntOrString(a, b *intstr.IntOrString) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
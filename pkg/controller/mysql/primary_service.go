@@ -0,0 +1,82 @@
+package mysql
+
+import (
+	"context"
+
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// syncPrimaryService 는 프라이머리만 고르는 쓰기용 서비스가 없는 경우 생성하고, 있는 경우 스펙과 다른 부분을 갱신한다
+// 자세한 주석은 syncService() 함수를 참고하길 바란다
+func (r *ReconcileMySQL) syncPrimaryService(mysql *mysqlv1alpha1.MySQL) error {
+	klog.Infof("[%s] syncPrimaryService", mysql.Name)
+	mysqlSvc := &corev1.Service{}
+	if err := r.client.Get(context.TODO(), getPrimaryServiceName(mysql), mysqlSvc); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		klog.Infof("[%s] Could not find mysql primary service. Create a new one", mysql.Name)
+		return r.createPrimaryService(mysql)
+	}
+	desired, err := newPrimaryService(mysql, r.scheme)
+	if err != nil {
+		return err
+	}
+	if !updateService(mysqlSvc, desired) {
+		return nil
+	}
+	klog.Infof("[%s] Update mysql primary service", mysql.Name)
+	return r.client.Update(context.TODO(), mysqlSvc)
+}
+
+// getPrimaryServiceName 는 쓰기용 서비스의 이름과 네임스페이스를 리턴한다
+func getPrimaryServiceName(mysql *mysqlv1alpha1.MySQL) types.NamespacedName {
+	return types.NamespacedName{Namespace: mysql.Namespace, Name: mysql.Name + "-primary"}
+}
+
+// createPrimaryService 는 새로운 쓰기용 서비스를 생성한다. 이미 서비스가 존재하는 경우 성공한다
+func (r *ReconcileMySQL) createPrimaryService(mysql *mysqlv1alpha1.MySQL) error {
+	mysqlPrimaryService, err := newPrimaryService(mysql, r.scheme)
+	if err != nil {
+		return err
+	}
+	if err := r.client.Create(context.TODO(), mysqlPrimaryService); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// newPrimaryService 는 쓰기용 서비스를 위한 객체를 생성한다. 객체는 mysql 객체를 오너로 가진다
+// 오퍼레이터가 프라이머리 파드에 붙이는 역할 레이블로 파드를 고르므로 페일오버 뒤에도 같은 주소로 새로운 프라이머리에 접속한다
+func newPrimaryService(mysql *mysqlv1alpha1.MySQL, scheme *runtime.Scheme) (*corev1.Service, error) {
+	selector := selectorForMySQL(mysql)
+	selector[labelRole] = rolePrimary
+	svc := &corev1.Service{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        getPrimaryServiceName(mysql).Name,
+			Namespace:   getPrimaryServiceName(mysql).Namespace,
+			Labels:      labelsForMySQL(mysql, componentService),
+			Annotations: annotationsForMySQL(mysql),
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name: "mysql",
+					Port: 3306,
+				},
+			},
+			Selector: selector,
+		},
+	}
+	if err := controllerutil.SetControllerReference(mysql, svc, scheme); err != nil {
+		return nil, err
+	}
+	return svc, nil
+}
@@ -2,6 +2,7 @@ package mysql
 
 import (
 	"context"
+
 	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -23,7 +24,15 @@ func (r *ReconcileMySQL) syncReadService(mysql *mysqlv1alpha1.MySQL) error {
 		klog.Infof("[%s] Could not find mysql read service. Create a new one", mysql.Name)
 		return r.createReadService(mysql)
 	}
-	return nil
+	desired, err := newReadService(mysql, r.scheme)
+	if err != nil {
+		return err
+	}
+	if !updateService(mysqlSvc, desired) {
+		return nil
+	}
+	klog.Infof("[%s] Update mysql read service", mysql.Name)
+	return r.client.Update(context.TODO(), mysqlSvc)
 }
 
 func getReadServiceName(mysql *mysqlv1alpha1.MySQL) types.NamespacedName {
@@ -44,8 +53,10 @@ func (r *ReconcileMySQL) createReadService(mysql *mysqlv1alpha1.MySQL) error {
 func newReadService(mysql *mysqlv1alpha1.MySQL, scheme *runtime.Scheme) (*corev1.Service, error) {
 	svc := &corev1.Service{
 		ObjectMeta: v1.ObjectMeta{
-			Name:      getReadServiceName(mysql).Name,
-			Namespace: getReadServiceName(mysql).Namespace,
+			Name:        getReadServiceName(mysql).Name,
+			Namespace:   getReadServiceName(mysql).Namespace,
+			Labels:      labelsForMySQL(mysql, componentService),
+			Annotations: annotationsForMySQL(mysql),
 		},
 		Spec: corev1.ServiceSpec{
 			Ports: []corev1.ServicePort{
@@ -54,9 +65,7 @@ func newReadService(mysql *mysqlv1alpha1.MySQL, scheme *runtime.Scheme) (*corev1
 					Port: 3306,
 				},
 			},
-			Selector: map[string]string{
-				"app": "mysql",
-			},
+			Selector: selectorForReadService(mysql),
 		},
 	}
 	if err := controllerutil.SetControllerReference(mysql, svc, scheme); err != nil {
@@ -64,3 +73,61 @@ func newReadService(mysql *mysqlv1alpha1.MySQL, scheme *runtime.Scheme) (*corev1
 	}
 	return svc, nil
 }
+
+// selectorForReadService 는 읽기용 서비스의 셀렉터를 리턴한다. 오퍼레이터가 읽기를 받을 멤버에 붙이는 레이블로 파드를 고른다
+func selectorForReadService(mysql *mysqlv1alpha1.MySQL) map[string]string {
+	selector := selectorForMySQL(mysql)
+	selector[labelReadable] = "true"
+	return selector
+}
+
+// getReadableMembers 는 읽기용 서비스가 고를 멤버를 리턴한다
+// 복제가 정상이고 지연이 MaxLagSeconds 이하인 준비된 레플리카를 고르며, 그런 레플리카가 없으면 프라이머리를 고른다
+// 접속할 수 없는 레플리카는 고르지 않는다
+func (r *ReconcileMySQL) getReadableMembers(mysql *mysqlv1alpha1.MySQL, pods []corev1.Pod) map[string]bool {
+	primaryName := getPrimaryPodName(mysql)
+	maxLag := int64(mysql.Spec.GetReadServiceMaxLagSeconds())
+	readable := map[string]bool{}
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Name == primaryName || !isPodReady(pod) {
+			continue
+		}
+		healthy, err := r.isReplicaReadable(mysql, pod.Name, maxLag)
+		if err != nil {
+			klog.Errorf("[%s] Could not check replication of %s: %v", mysql.Name, pod.Name, err)
+			continue
+		}
+		if healthy {
+			readable[pod.Name] = true
+		}
+	}
+	if len(readable) == 0 {
+		readable[primaryName] = true
+	}
+	return readable
+}
+
+// isReplicaReadable 는 레플리카가 읽기를 받을 수 있는지 리턴한다
+// 그룹 복제의 세컨더리는 그룹에 참여하고 있으면 읽기를 받는다
+func (r *ReconcileMySQL) isReplicaReadable(mysql *mysqlv1alpha1.MySQL, podName string, maxLag int64) (bool, error) {
+	if mysql.Spec.GetTopology() == mysqlv1alpha1.TopologyGroupReplication {
+		member, err := r.getGroupMember(mysql, podName)
+		if err != nil {
+			return false, err
+		}
+		return member.State == groupMemberOnline, nil
+	}
+	position, err := r.getReplicaPosition(mysql, podName)
+	if err != nil {
+		return false, err
+	}
+	if !position.ioRunning || !position.sqlRunning || position.secondsBehind == nil {
+		return false, nil
+	}
+	if *position.secondsBehind > maxLag {
+		klog.Infof("[%s] %s is %d seconds behind the primary", mysql.Name, podName, *position.secondsBehind)
+		return false, nil
+	}
+	return true, nil
+}
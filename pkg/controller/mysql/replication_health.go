@@ -0,0 +1,172 @@
+package mysql
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+// 복제 상태의 컨디션 원인(reason)
+const (
+	reasonReplicating       status.ConditionReason = "Replicating"
+	reasonPermanentFailures status.ConditionReason = "PermanentReplicationFailures"
+)
+
+const (
+	// replicationCheckInterval 은 멤버의 복제 상태를 다시 확인하기까지 기다리는 시간이다
+	// 복제가 멈추거나 지연되어도 쿠버네티스 객체는 바뀌지 않으므로 주기적으로 조정 루프에 진입해서 확인한다
+	replicationCheckInterval = 30 * time.Second
+	// replicationSetupGracePeriod 는 레플리카가 준비된 뒤 xtrabackup 사이드카가 복제를 설정하기를 기다리는 시간이다
+	replicationSetupGracePeriod = time.Minute
+)
+
+// transientReplicationErrors 는 복제 스레드를 다시 시작하면 해결될 수 있는 에러 번호이다
+// 네트워크 에러나 잠금 대기처럼 데이터와 관계없는 에러이며, 그 외의 에러는 데이터가 어긋났거나 설정이 잘못된 것으로 본다
+var transientReplicationErrors = map[int]bool{
+	1040: true, // ER_CON_COUNT_ERROR
+	1158: true, // ER_NET_READ_ERROR
+	1159: true, // ER_NET_READ_INTERRUPTED
+	1160: true, // ER_NET_ERROR_ON_WRITE
+	1161: true, // ER_NET_WRITE_INTERRUPTED
+	1205: true, // ER_LOCK_WAIT_TIMEOUT
+	1213: true, // ER_LOCK_DEADLOCK
+	2003: true, // CR_CONN_HOST_ERROR
+	2005: true, // CR_UNKNOWN_HOST
+	2006: true, // CR_SERVER_GONE_ERROR
+	2013: true, // CR_SERVER_LOST
+}
+
+// syncReplication 은 준비된 레플리카의 복제 스레드가 멈췄다면 다시 시작한다
+// 다시 시작해도 해결되지 않는 이유로 멈춘 레플리카는 그대로 두고 ReplicationBroken 컨디션으로 알린다
+// IO 스레드가 프라이머리에 다시 접속하는 중(Connecting)이면 스스로 다시 시도하므로 그대로 둔다
+func (r *ReconcileMySQL) syncReplication(mysql *mysqlv1alpha1.MySQL, primaryName string, pods []corev1.Pod) error {
+	primaryHost := getMemberHost(mysql, primaryName)
+	for i := range pods {
+		name := pods[i].Name
+		if name == primaryName || !isPodReady(&pods[i]) || isDepartingMember(mysql, &pods[i]) {
+			continue
+		}
+		position, err := r.getReplicaPosition(mysql, name)
+		if err != nil {
+			klog.Errorf("[%s] Could not get replication status of %s: %v", mysql.Name, name, err)
+			continue
+		}
+		if failure := getReplicationFailure(position, primaryHost); failure != "" {
+			klog.Errorf("[%s] Replication of %s is broken: %s", mysql.Name, name, failure)
+			continue
+		}
+		if position.ioState != "No" && position.sqlState != "No" {
+			continue
+		}
+		klog.Infof("[%s] Restart replication on %s (last error: %s)", mysql.Name, name, position.lastError())
+		if _, err := r.runSQL(mysql, name, "START SLAVE"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getReplicationFailure 는 레플리카의 복제가 다시 시작해도 해결되지 않는 이유로 멈췄다면 그 이유를 리턴한다
+// 프라이머리가 아닌 멤버를 복제하는 레플리카는 페일오버에서 뒤처져 다시 복제(clone)해야 하는 경우이므로 포함한다
+// 프라이머리에 다시 접속하는 중(Connecting)인 IO 스레드는 스스로 다시 시도하므로 포함하지 않는다
+func getReplicationFailure(position *replicaPosition, primaryHost string) string {
+	if position.primaryHost != primaryHost {
+		return fmt.Sprintf("replicating from %s instead of the primary", position.primaryHost)
+	}
+	if position.ioState == "No" && position.ioErrno != 0 && !transientReplicationErrors[position.ioErrno] {
+		return fmt.Sprintf("IO thread stopped with error %d: %s", position.ioErrno, position.ioError)
+	}
+	if !position.sqlRunning && position.sqlErrno != 0 && !transientReplicationErrors[position.sqlErrno] {
+		return fmt.Sprintf("SQL thread stopped with error %d: %s", position.sqlErrno, position.sqlError)
+	}
+	return ""
+}
+
+// lastError 는 복제 스레드의 마지막 에러를 리턴한다. SQL 스레드의 에러를 먼저 리턴한다
+func (p *replicaPosition) lastError() string {
+	switch {
+	case p.sqlErrno != 0:
+		return fmt.Sprintf("error %d: %s", p.sqlErrno, p.sqlError)
+	case p.ioErrno != 0:
+		return fmt.Sprintf("error %d: %s", p.ioErrno, p.ioError)
+	}
+	return ""
+}
+
+// observeMembers 는 각 멤버의 역할과 복제 상태로 Members 와 ReplicationBroken 컨디션을 채운다
+// 준비된 뒤에도 오랫동안 복제를 설정하지 않은 레플리카는 다시 복제(clone)해야 하므로 복제가 깨진 것으로 본다
+// 그룹 복제는 GroupMembers 에 상태를 기록하므로 채우지 않는다
+func (r *ReconcileMySQL) observeMembers(mysql *mysqlv1alpha1.MySQL, newStatus *mysqlv1alpha1.MySQLStatus, pods []corev1.Pod) {
+	if mysql.Spec.GetTopology() == mysqlv1alpha1.TopologyGroupReplication {
+		newStatus.Members = nil
+		newStatus.Conditions.RemoveCondition(mysqlv1alpha1.ConditionReplicationBroken)
+		return
+	}
+	podByName := make(map[string]*corev1.Pod, len(pods))
+	for i := range pods {
+		podByName[pods[i].Name] = &pods[i]
+	}
+	primaryName := getPrimaryPodName(mysql)
+	primaryHost := getMemberHost(mysql, primaryName)
+
+	members := make([]mysqlv1alpha1.MemberStatus, 0, mysql.Spec.GetReplicas())
+	var broken []string
+	for i := 0; i < int(mysql.Spec.GetReplicas()); i++ {
+		name := getPodName(mysql, i)
+		member := mysqlv1alpha1.MemberStatus{Name: name, Role: roleReplica}
+		pod, ok := podByName[name]
+		switch {
+		case name == primaryName:
+			member.Role = rolePrimary
+		case !ok || !isPodReady(pod):
+		default:
+			position, err := r.getReplicaPosition(mysql, name)
+			if _, ok := err.(*notReplicatingError); ok {
+				if time.Since(getPodReadyTime(pod)) > replicationSetupGracePeriod {
+					broken = append(broken, fmt.Sprintf("%s: not replicating", name))
+				}
+				break
+			}
+			if err != nil {
+				klog.Errorf("[%s] Could not get replication status of %s: %v", mysql.Name, name, err)
+				break
+			}
+			member.IOThread = position.ioState
+			member.SQLThread = position.sqlState
+			member.LastError = position.lastError()
+			member.SecondsBehindPrimary = position.secondsBehind
+			if failure := getReplicationFailure(position, primaryHost); failure != "" {
+				broken = append(broken, fmt.Sprintf("%s: %s", name, failure))
+			}
+		}
+		members = append(members, member)
+	}
+	newStatus.Members = members
+
+	condition := status.Condition{
+		Type:   mysqlv1alpha1.ConditionReplicationBroken,
+		Status: corev1.ConditionFalse,
+		Reason: reasonReplicating,
+	}
+	if len(broken) > 0 {
+		condition.Status = corev1.ConditionTrue
+		condition.Reason = reasonPermanentFailures
+		condition.Message = strings.Join(broken, "; ")
+	}
+	newStatus.Conditions.SetCondition(condition)
+}
+
+// getPodReadyTime 는 파드가 마지막으로 준비(Ready) 상태가 된 시각을 리턴한다
+func getPodReadyTime(pod *corev1.Pod) time.Time {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.LastTransitionTime.Time
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,56 @@
+package mysql
+
+import "testing"
+
+func TestGetReplicationFailure(t *testing.T) {
+	const primaryHost = "mysql-0.mysql.default.svc"
+	tests := []struct {
+		name     string
+		position replicaPosition
+		broken   bool
+	}{
+		{
+			name:     "replicating",
+			position: replicaPosition{primaryHost: primaryHost, ioState: "Yes", ioRunning: true, sqlState: "Yes", sqlRunning: true},
+		},
+		{
+			name:     "replicating from another member",
+			position: replicaPosition{primaryHost: "mysql-1.mysql.default.svc", ioState: "Yes", ioRunning: true, sqlState: "Yes", sqlRunning: true},
+			broken:   true,
+		},
+		{
+			name:     "IO thread stopped by a network error",
+			position: replicaPosition{primaryHost: primaryHost, ioState: "No", ioErrno: 2003, sqlState: "Yes", sqlRunning: true},
+		},
+		{
+			name:     "IO thread stopped by a purged binary log",
+			position: replicaPosition{primaryHost: primaryHost, ioState: "No", ioErrno: 1236, sqlState: "Yes", sqlRunning: true},
+			broken:   true,
+		},
+		{
+			name:     "IO thread reconnecting after an access error",
+			position: replicaPosition{primaryHost: primaryHost, ioState: "Connecting", ioErrno: 1045, sqlState: "Yes", sqlRunning: true},
+		},
+		{
+			name:     "SQL thread stopped by a lock wait timeout",
+			position: replicaPosition{primaryHost: primaryHost, ioState: "Yes", ioRunning: true, sqlState: "No", sqlErrno: 1205},
+		},
+		{
+			name:     "SQL thread stopped by a duplicate key",
+			position: replicaPosition{primaryHost: primaryHost, ioState: "Yes", ioRunning: true, sqlState: "No", sqlErrno: 1062},
+			broken:   true,
+		},
+		{
+			name:     "stopped without an error",
+			position: replicaPosition{primaryHost: primaryHost, ioState: "No", sqlState: "No"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getReplicationFailure(&tt.position, primaryHost)
+			if (got != "") != tt.broken {
+				t.Errorf("getReplicationFailure() = %q, want broken %v", got, tt.broken)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/klog"
+)
+
+// 멤버를 제거할 수 없는 경우의 컨디션 원인(reason)
+const (
+	reasonPrimaryRemoval status.ConditionReason = "PrimaryRemoval"
+)
+
+// prepareScaleDown 는 스테이트풀셋의 멤버 수를 current 에서 desired 로 줄이기 전에 제거될 멤버를 정리한다
+// 스테이트풀셋은 가장 큰 순번부터 멤버를 제거하므로 desired 이상의 순번을 가진 멤버가 제거된다
+// 프라이머리나 다른 멤버가 복제하고 있는 멤버는 제거하지 않으며, 레플리카는 복제를 멈추고 복제 설정을 지운다
+func (r *ReconcileMySQL) prepareScaleDown(mysql *mysqlv1alpha1.MySQL, current, desired int32) error {
+	klog.Infof("[%s] Prepare to scale down from %d to %d members", mysql.Name, current, desired)
+	pods, err := r.listPods(mysql)
+	if err != nil {
+		return err
+	}
+	podByName := make(map[string]*corev1.Pod, len(pods))
+	for i := range pods {
+		podByName[pods[i].Name] = &pods[i]
+	}
+
+	primaryName := getPrimaryPodName(mysql)
+	for ordinal := desired; ordinal < current; ordinal++ {
+		name := getPodName(mysql, int(ordinal))
+		if name == primaryName {
+			return &specError{reason: reasonPrimaryRemoval,
+				message: fmt.Sprintf("scaling down to %d members would remove primary %s", desired, name)}
+		}
+		// 준비되지 않은 멤버는 접속할 수 없고, 곧 제거되므로 정리하지 않는다
+		pod, ok := podByName[name]
+		if !ok || !isPodReady(pod) {
+			klog.Infof("[%s] Skip cleaning up departing member %s which is not ready", mysql.Name, name)
+			continue
+		}
+		// 그룹 복제는 멤버가 그룹을 떠나면 남은 멤버끼리 계속 복제한다
+		if mysql.Spec.GetTopology() == mysqlv1alpha1.TopologyGroupReplication {
+			klog.Infof("[%s] Leave group replication on departing member %s", mysql.Name, name)
+			if _, err := r.runSQL(mysql, name, "STOP GROUP_REPLICATION"); err != nil {
+				return err
+			}
+			continue
+		}
+		// 다른 멤버가 이 멤버를 복제하고 있다면 이 멤버는 레플리카가 아니다
+		replicas, err := r.runSQL(mysql, name, "SHOW SLAVE HOSTS")
+		if err != nil {
+			return err
+		}
+		if len(replicas) > 0 {
+			return &specError{reason: reasonPrimaryRemoval,
+				message: fmt.Sprintf("scaling down to %d members would remove %s which has %d replicas", desired, name, len(replicas))}
+		}
+		klog.Infof("[%s] Stop replication on departing member %s", mysql.Name, name)
+		if _, err := r.runSQL(mysql, name, "STOP SLAVE; RESET SLAVE ALL"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncDepartedDataVolumeClaims 는 DeleteOnScaleDown 이 true 이면 제거된 멤버의 데이터 볼륨 클레임을 삭제한다
+// 멤버의 파드가 완전히 사라진 다음에 삭제해야 다시 멤버의 수를 늘렸을 때 삭제 중인 볼륨 클레임을 사용하지 않는다
+func (r *ReconcileMySQL) syncDepartedDataVolumeClaims(mysql *mysqlv1alpha1.MySQL) error {
+	if mysql.Spec.Storage == nil || !mysql.Spec.Storage.DeleteOnScaleDown {
+		return nil
+	}
+	klog.Infof("[%s] syncDepartedDataVolumeClaims", mysql.Name)
+	claims, err := r.listDataVolumeClaims(mysql)
+	if err != nil {
+		return err
+	}
+	pods, err := r.listPods(mysql)
+	if err != nil {
+		return err
+	}
+	podNames := make(map[string]bool, len(pods))
+	for i := range pods {
+		podNames[pods[i].Name] = true
+	}
+
+	replicas := mysql.Spec.GetReplicas()
+	for i := range claims {
+		claim := &claims[i]
+		ordinal, ok := getDataVolumeClaimOrdinal(mysql, claim)
+		if !ok || ordinal < replicas || podNames[getPodName(mysql, int(ordinal))] || claim.DeletionTimestamp != nil {
+			continue
+		}
+		klog.Infof("[%s] Delete data volume claim %s of departed member", mysql.Name, claim.Name)
+		if err := r.client.Delete(context.TODO(), claim); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// getDataVolumeClaimOrdinal 는 스테이트풀셋이 만든 data-<파드 이름> 볼륨 클레임의 멤버 순번을 리턴한다
+func getDataVolumeClaimOrdinal(mysql *mysqlv1alpha1.MySQL, claim *corev1.PersistentVolumeClaim) (int32, bool) {
+	prefix := dataVolumeName + "-"
+	if !strings.HasPrefix(claim.Name, prefix) {
+		return 0, false
+	}
+	return getPodOrdinal(mysql, strings.TrimPrefix(claim.Name, prefix))
+}
+
+// getPodOrdinal 는 <스테이트풀셋 이름>-<순번> 형식인 멤버 파드 이름의 순번을 리턴한다
+func getPodOrdinal(mysql *mysqlv1alpha1.MySQL, podName string) (int32, bool) {
+	prefix := getStatefulSetName(mysql).Name + "-"
+	if !strings.HasPrefix(podName, prefix) {
+		return 0, false
+	}
+	ordinal, err := strconv.ParseInt(strings.TrimPrefix(podName, prefix), 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return int32(ordinal), true
+}
+
+// isDepartingMember 는 멤버의 수가 줄어들어 스테이트풀셋이 제거할 파드인지 리턴한다
+// 스테이트풀셋이 파드를 지우기 전까지는 캐시에 준비된 파드로 남아 있으므로, prepareScaleDown 이 정리한 멤버를
+// 같은 조정 루프에서 다시 그룹에 참여시키거나 복제를 설정하지 않도록 제외한다
+func isDepartingMember(mysql *mysqlv1alpha1.MySQL, pod *corev1.Pod) bool {
+	ordinal, ok := getPodOrdinal(mysql, pod.Name)
+	return ok && ordinal >= mysql.Spec.GetReplicas()
+}
@@ -0,0 +1,53 @@
+package mysql
+
+import (
+	"testing"
+
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetDataVolumeClaimOrdinal(t *testing.T) {
+	mysql := &mysqlv1alpha1.MySQL{ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: "default"}}
+	tests := []struct {
+		claim   string
+		ordinal int32
+		ok      bool
+	}{
+		{claim: "data-mysql-0", ordinal: 0, ok: true},
+		{claim: "data-mysql-12", ordinal: 12, ok: true},
+		{claim: "data-mysql-read-0", ok: false},
+		{claim: "data-other-1", ok: false},
+		{claim: "mysql-1", ok: false},
+	}
+	for _, tt := range tests {
+		claim := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: tt.claim}}
+		ordinal, ok := getDataVolumeClaimOrdinal(mysql, claim)
+		if ordinal != tt.ordinal || ok != tt.ok {
+			t.Errorf("getDataVolumeClaimOrdinal(%q) = %d, %v, want %d, %v", tt.claim, ordinal, ok, tt.ordinal, tt.ok)
+		}
+	}
+}
+
+func TestIsDepartingMember(t *testing.T) {
+	replicas := int32(2)
+	mysql := &mysqlv1alpha1.MySQL{
+		ObjectMeta: metav1.ObjectMeta{Name: "mysql", Namespace: "default"},
+		Spec:       mysqlv1alpha1.MySQLSpec{Replicas: &replicas},
+	}
+	tests := map[string]bool{
+		"mysql-0":       false,
+		"mysql-1":       false,
+		"mysql-2":       true,
+		"mysql-10":      true,
+		"other-3":       false,
+		"mysql-primary": false,
+	}
+	for name, want := range tests {
+		pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if got := isDepartingMember(mysql, pod); got != want {
+			t.Errorf("isDepartingMember(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package mysql
+
+import (
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// hostnameTopologyKey 는 노드를 구분하는 레이블이다. 안티 어피니티에 사용한다
+	hostnameTopologyKey = "kubernetes.io/hostname"
+	// zoneTopologyKey 는 노드가 속한 존을 나타내는 레이블이다. 존 분산에 사용한다
+	zoneTopologyKey = "topology.kubernetes.io/zone"
+	// antiAffinityWeight 는 preferred 정책에서 안티 어피니티 규칙의 가중치이다
+	antiAffinityWeight = 100
+)
+
+// applyPodTemplate 는 스케줄링 설정을 멤버 파드의 스펙에 넣는다
+// 사용자가 지정한 설정에 안티 어피니티 정책에 따른 규칙을 더한다
+func applyPodTemplate(mysql *mysqlv1alpha1.MySQL, podSpec *corev1.PodSpec) {
+	template := mysql.Spec.PodTemplate
+	if template == nil {
+		template = &mysqlv1alpha1.PodTemplate{}
+	}
+	podSpec.NodeSelector = template.NodeSelector
+	podSpec.Affinity = newAffinity(mysql, template.Affinity)
+	podSpec.Tolerations = template.Tolerations
+	podSpec.PriorityClassName = template.PriorityClassName
+	podSpec.TopologySpreadConstraints = newTopologySpreadConstraints(mysql, template.TopologySpreadConstraints)
+}
+
+// newAffinity 는 사용자가 지정한 어피니티에 같은 MySQL 의 멤버끼리 같은 노드를 피하는 안티 어피니티 규칙을 더한다
+func newAffinity(mysql *mysqlv1alpha1.MySQL, affinity *corev1.Affinity) *corev1.Affinity {
+	policy := mysql.Spec.GetAntiAffinity()
+	if policy == mysqlv1alpha1.AntiAffinityNone {
+		return affinity
+	}
+	// 사용자의 스펙을 바꾸지 않도록 복사해서 사용한다
+	affinity = affinity.DeepCopy()
+	if affinity == nil {
+		affinity = &corev1.Affinity{}
+	}
+	if affinity.PodAntiAffinity == nil {
+		affinity.PodAntiAffinity = &corev1.PodAntiAffinity{}
+	}
+	term := corev1.PodAffinityTerm{
+		LabelSelector: &metav1.LabelSelector{MatchLabels: selectorForMySQL(mysql)},
+		TopologyKey:   hostnameTopologyKey,
+	}
+	antiAffinity := affinity.PodAntiAffinity
+	if policy == mysqlv1alpha1.AntiAffinityRequired {
+		antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution =
+			append(antiAffinity.RequiredDuringSchedulingIgnoredDuringExecution, term)
+	} else {
+		antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution =
+			append(antiAffinity.PreferredDuringSchedulingIgnoredDuringExecution, corev1.WeightedPodAffinityTerm{
+				Weight:          antiAffinityWeight,
+				PodAffinityTerm: term,
+			})
+	}
+	return affinity
+}
+
+// newTopologySpreadConstraints 는 멤버를 여러 존에 분산시키는 규칙을 리턴한다
+// 사용자가 규칙을 지정했다면 그대로 사용한다. 존의 수가 멤버의 수보다 적을 수 있으므로 분산할 수 없더라도 파드를 배치한다
+func newTopologySpreadConstraints(mysql *mysqlv1alpha1.MySQL, constraints []corev1.TopologySpreadConstraint) []corev1.TopologySpreadConstraint {
+	if len(constraints) != 0 || mysql.Spec.GetAntiAffinity() == mysqlv1alpha1.AntiAffinityNone {
+		return constraints
+	}
+	return []corev1.TopologySpreadConstraint{
+		{
+			MaxSkew:           1,
+			TopologyKey:       zoneTopologyKey,
+			WhenUnsatisfiable: corev1.ScheduleAnyway,
+			LabelSelector:     &metav1.LabelSelector{MatchLabels: selectorForMySQL(mysql)},
+		},
+	}
+}
+
+// equalScheduling 는 두 파드 스펙의 스케줄링 설정이 같은지 리턴한다
+// API 서버는 파드 템플릿의 스케줄링 설정에 기본값을 채우지 않으므로 정확히 비교해서 사용자가 지운 설정도 찾아낸다
+func equalScheduling(desired, live *corev1.PodSpec) bool {
+	return equality.Semantic.DeepEqual(desired.NodeSelector, live.NodeSelector) &&
+		equality.Semantic.DeepEqual(desired.Affinity, live.Affinity) &&
+		equality.Semantic.DeepEqual(desired.Tolerations, live.Tolerations) &&
+		desired.PriorityClassName == live.PriorityClassName &&
+		equality.Semantic.DeepEqual(desired.TopologySpreadConstraints, live.TopologySpreadConstraints)
+}
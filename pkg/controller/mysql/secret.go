@@ -0,0 +1,184 @@
+package mysql
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// passwordKey 는 오퍼레이터가 만든 시크릿에서 비밀번호를 가진 키이다
+	passwordKey = "password"
+	// passwordLength 는 오퍼레이터가 만드는 비밀번호의 길이이다
+	passwordLength = 24
+	// passwordCharacters 는 비밀번호에 사용하는 문자이다. 스크립트와 SQL 에 그대로 넣을 수 있도록 영문자와 숫자만 사용한다
+	passwordCharacters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// syncRootPasswordSecret 는 root 비밀번호를 가진 시크릿을 확인한다
+// 사용자가 시크릿을 지정했다면 존재하는지만 확인하고, 그렇지 않다면 무작위 비밀번호를 가진 시크릿을 생성한다
+// 이미 만들어진 비밀번호는 데이터 디렉터리에 기록되어 있으므로 절대 바꾸지 않는다
+func (r *ReconcileMySQL) syncRootPasswordSecret(mysql *mysqlv1alpha1.MySQL) error {
+	klog.Infof("[%s] syncRootPasswordSecret", mysql.Name)
+	ref := getRootPasswordSecretRef(mysql)
+	secret := &corev1.Secret{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: ref.Name}, secret); err != nil {
+		// Not Found 에러가 아닌 경우는 가져오는데 실패한 것이므로 에러를 바로 리턴한다
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		// 사용자가 지정한 시크릿은 오퍼레이터가 만들 수 없다
+		if mysql.Spec.RootPasswordSecretRef != nil {
+			return fmt.Errorf("root password secret %s not found", ref.Name)
+		}
+		klog.Infof("[%s] Could not find mysql root password secret. Create a new one", mysql.Name)
+		return r.createRootPasswordSecret(mysql)
+	}
+	if _, ok := secret.Data[ref.Key]; !ok {
+		return fmt.Errorf("root password secret %s has no key %s", ref.Name, ref.Key)
+	}
+	if mysql.Spec.RootPasswordSecretRef != nil {
+		return nil
+	}
+	return r.adoptCredentialSecret(mysql, secret)
+}
+
+// getRootPasswordSecretName 는 오퍼레이터가 만드는 root 비밀번호 시크릿의 이름과 네임스페이스를 리턴한다
+func getRootPasswordSecretName(mysql *mysqlv1alpha1.MySQL) types.NamespacedName {
+	return types.NamespacedName{Namespace: mysql.Namespace, Name: mysql.Name + "-root-password"}
+}
+
+// getRootPasswordSecretRef 는 root 비밀번호를 가진 시크릿의 키를 리턴한다
+func getRootPasswordSecretRef(mysql *mysqlv1alpha1.MySQL) *corev1.SecretKeySelector {
+	if mysql.Spec.RootPasswordSecretRef != nil {
+		return mysql.Spec.RootPasswordSecretRef
+	}
+	return &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: getRootPasswordSecretName(mysql).Name},
+		Key:                  passwordKey,
+	}
+}
+
+// createRootPasswordSecret 는 무작위 비밀번호를 가진 새로운 시크릿을 생성한다. 이미 시크릿이 존재하는 경우 성공한다
+func (r *ReconcileMySQL) createRootPasswordSecret(mysql *mysqlv1alpha1.MySQL) error {
+	secret, err := newRootPasswordSecret(mysql, r.scheme)
+	if err != nil {
+		return err
+	}
+	if err := r.client.Create(context.TODO(), secret); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// newRootPasswordSecret 는 root 비밀번호 시크릿을 위한 객체를 생성한다. 객체는 mysql 객체를 오너로 가진다
+func newRootPasswordSecret(mysql *mysqlv1alpha1.MySQL, scheme *runtime.Scheme) (*corev1.Secret, error) {
+	password, err := generatePassword()
+	if err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        getRootPasswordSecretName(mysql).Name,
+			Namespace:   getRootPasswordSecretName(mysql).Namespace,
+			Labels:      labelsForMySQL(mysql, componentCredentials),
+			Annotations: annotationsForMySQL(mysql),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			passwordKey: []byte(password),
+		},
+	}
+	if err := controllerutil.SetControllerReference(mysql, secret, scheme); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// getCredentialSecretNames 는 오퍼레이터가 만든 비밀번호 시크릿의 이름을 리턴한다. 사용자가 지정한 root 비밀번호 시크릿은 포함하지 않는다
+func getCredentialSecretNames(mysql *mysqlv1alpha1.MySQL) []types.NamespacedName {
+	var names []types.NamespacedName
+	if mysql.Spec.RootPasswordSecretRef == nil {
+		names = append(names, getRootPasswordSecretName(mysql))
+	}
+	for _, user := range systemUsers {
+		names = append(names, getSystemUserSecretName(mysql, user))
+	}
+	return names
+}
+
+// orphanCredentialSecrets 는 오퍼레이터가 만든 비밀번호 시크릿에서 mysql 객체의 오너 레퍼런스를 지워서 가비지 컬렉션되지 않게 한다
+// 남겨둔 데이터 디렉터리의 계정은 이 비밀번호를 가지므로, 같은 이름의 MySQL 객체를 다시 만들면 시크릿을 그대로 이어받는다
+func (r *ReconcileMySQL) orphanCredentialSecrets(mysql *mysqlv1alpha1.MySQL) error {
+	for _, name := range getCredentialSecretNames(mysql) {
+		secret := &corev1.Secret{}
+		if err := r.client.Get(context.TODO(), name, secret); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		var owners []v1.OwnerReference
+		for _, owner := range secret.OwnerReferences {
+			if owner.UID != mysql.UID {
+				owners = append(owners, owner)
+			}
+		}
+		if len(owners) == len(secret.OwnerReferences) {
+			continue
+		}
+		klog.Infof("[%s] Orphan secret %s to keep it with the retained data", mysql.Name, secret.Name)
+		secret.OwnerReferences = owners
+		if err := r.client.Update(context.TODO(), secret); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// adoptCredentialSecret 는 이전 MySQL 객체가 데이터와 함께 남겨둔 비밀번호 시크릿을 mysql 객체가 소유하도록 한다
+func (r *ReconcileMySQL) adoptCredentialSecret(mysql *mysqlv1alpha1.MySQL, secret *corev1.Secret) error {
+	if v1.GetControllerOf(secret) != nil {
+		return nil
+	}
+	klog.Infof("[%s] Adopt secret %s retained by a previous mysql", mysql.Name, secret.Name)
+	if err := controllerutil.SetControllerReference(mysql, secret, r.scheme); err != nil {
+		return err
+	}
+	return r.client.Update(context.TODO(), secret)
+}
+
+// generatePassword 는 암호학적으로 안전한 무작위 비밀번호를 만든다
+func generatePassword() (string, error) {
+	password := make([]byte, passwordLength)
+	max := big.NewInt(int64(len(passwordCharacters)))
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		password[i] = passwordCharacters[n.Int64()]
+	}
+	return string(password), nil
+}
+
+// newRootPasswordEnv 는 root 비밀번호를 시크릿에서 읽어오는 환경변수를 리턴한다
+// mysql 이미지는 데이터 디렉터리를 처음 초기화할 때 이 환경변수로 root 비밀번호를 설정한다
+func newRootPasswordEnv(mysql *mysqlv1alpha1.MySQL) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: "MYSQL_ROOT_PASSWORD",
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: getRootPasswordSecretRef(mysql),
+		},
+	}
+}
@@ -0,0 +1,120 @@
+package mysql
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/klog"
+)
+
+// 반동기 복제의 컨디션 원인(reason)
+const (
+	reasonSemiSyncActive  status.ConditionReason = "SemiSyncActive"
+	reasonDegradedToAsync status.ConditionReason = "DegradedToAsync"
+	reasonInvalidSemiSync status.ConditionReason = "InvalidSemiSync"
+)
+
+// semiSyncCheckInterval 은 반동기 복제가 비동기로 바뀌었는지 다시 확인하기까지 기다리는 시간이다
+// 프라이머리가 비동기로 바뀌어도 쿠버네티스 객체는 바뀌지 않으므로 주기적으로 조정 루프에 진입해서 확인한다
+const semiSyncCheckInterval = 30 * time.Second
+
+// getSemiSyncConfig 는 반동기 복제를 사용할 때 멤버의 역할에 따라 적용하는 설정을 리턴한다
+// 어느 멤버든 프라이머리로 승격될 수 있으므로 모든 멤버에 프라이머리와 레플리카의 플러그인을 모두 설치하고,
+// 프라이머리만 레플리카의 응답을 기다린다. 프라이머리가 바뀌면 ensureRoles 가 실행 중인 멤버의 설정을 바꾼다
+func getSemiSyncConfig(mysql *mysqlv1alpha1.MySQL, primary bool) mysqlv1alpha1.MySQLConfig {
+	if !mysql.Spec.IsSemiSyncEnabled() {
+		return nil
+	}
+	primaryEnabled := "OFF"
+	if primary {
+		primaryEnabled = "ON"
+	}
+	return mysqlv1alpha1.MySQLConfig{
+		"mysqld": {
+			"plugin-load-add":                           "semisync_master.so;semisync_slave.so",
+			"rpl-semi-sync-master-enabled":              primaryEnabled,
+			"rpl-semi-sync-master-timeout":              strconv.Itoa(int(mysql.Spec.GetSemiSyncTimeoutSeconds()) * 1000),
+			"rpl-semi-sync-master-wait-for-slave-count": strconv.Itoa(int(mysql.Spec.GetSemiSyncWaitForReplicaCount())),
+			"rpl-semi-sync-slave-enabled":               "ON",
+		},
+	}
+}
+
+// validateSemiSync 는 응답을 기다리는 레플리카의 수가 클러스터의 레플리카 수를 넘지 않는지 확인한다
+// 레플리카가 모자라면 프라이머리는 모든 커밋에서 타임아웃까지 기다린 뒤 비동기로 바뀐다
+func validateSemiSync(mysql *mysqlv1alpha1.MySQL) error {
+	if !mysql.Spec.IsSemiSyncEnabled() {
+		return nil
+	}
+	if count := mysql.Spec.GetSemiSyncWaitForReplicaCount(); count > mysql.Spec.GetReplicas()-1 {
+		return &specError{reason: reasonInvalidSemiSync,
+			message: fmt.Sprintf("semi-synchronous replication waiting for %d replicas needs at least %d members", count, count+1)}
+	}
+	return nil
+}
+
+// setSemiSyncPrimary 는 실행 중인 멤버가 커밋할 때 레플리카의 응답을 기다릴지 바꾼다
+func (r *ReconcileMySQL) setSemiSyncPrimary(mysql *mysqlv1alpha1.MySQL, podName string, enabled bool) error {
+	rows, err := r.runSQL(mysql, podName, "SELECT @@global.rpl_semi_sync_master_enabled AS enabled")
+	if err != nil {
+		return err
+	}
+	if len(rows) == 1 && (rows[0]["enabled"] == "1") == enabled {
+		return nil
+	}
+	value := "OFF"
+	if enabled {
+		value = "ON"
+	}
+	klog.Infof("[%s] Set rpl_semi_sync_master_enabled of %s to %s", mysql.Name, podName, value)
+	_, err = r.runSQL(mysql, podName, "SET GLOBAL rpl_semi_sync_master_enabled = "+value)
+	return err
+}
+
+// observeSemiSync 는 프라이머리가 레플리카의 응답을 기다리고 있는지 확인해서 SemiSynchronous 컨디션을 채운다
+// 프라이머리에 접속할 수 없으면 컨디션을 바꾸지 않는다
+func (r *ReconcileMySQL) observeSemiSync(mysql *mysqlv1alpha1.MySQL, newStatus *mysqlv1alpha1.MySQLStatus, pods []corev1.Pod) {
+	if !mysql.Spec.IsSemiSyncEnabled() {
+		newStatus.Conditions.RemoveCondition(mysqlv1alpha1.ConditionSemiSync)
+		return
+	}
+	primaryName := getPrimaryPodName(mysql)
+	primaryReady := false
+	for i := range pods {
+		if pods[i].Name == primaryName && isPodReady(&pods[i]) {
+			primaryReady = true
+		}
+	}
+	if !primaryReady {
+		return
+	}
+	rows, err := r.runSQL(mysql, primaryName,
+		"SHOW GLOBAL STATUS WHERE Variable_name IN ('Rpl_semi_sync_master_status', 'Rpl_semi_sync_master_clients')")
+	if err != nil {
+		klog.Errorf("[%s] Could not get semi-synchronous replication status of %s: %v", mysql.Name, primaryName, err)
+		return
+	}
+	values := make(map[string]string, len(rows))
+	for _, row := range rows {
+		values[row["Variable_name"]] = row["Value"]
+	}
+
+	count := mysql.Spec.GetSemiSyncWaitForReplicaCount()
+	condition := status.Condition{
+		Type:    mysqlv1alpha1.ConditionSemiSync,
+		Status:  corev1.ConditionTrue,
+		Reason:  reasonSemiSyncActive,
+		Message: fmt.Sprintf("primary %s waits for %d of %s semi-synchronous replicas", primaryName, count, values["Rpl_semi_sync_master_clients"]),
+	}
+	if values["Rpl_semi_sync_master_status"] != "ON" {
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = reasonDegradedToAsync
+		condition.Message = fmt.Sprintf("primary %s has fallen back to asynchronous replication with %s of %d semi-synchronous replicas",
+			primaryName, values["Rpl_semi_sync_master_clients"], count)
+	}
+	newStatus.Conditions.SetCondition(condition)
+}
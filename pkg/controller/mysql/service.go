@@ -4,6 +4,7 @@ import (
 	"context"
 	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -12,9 +13,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-// syncReadService 는 mysql 서비스가 없는 경우 서비스를 생성한다
+// syncService 는 mysql 서비스가 없는 경우 서비스를 생성하고, 있는 경우 스펙과 다른 부분을 갱신한다
 func (r *ReconcileMySQL) syncService(mysql *mysqlv1alpha1.MySQL) error {
-	klog.Infof("[%s] syncReadService", mysql.Name)
+	klog.Infof("[%s] syncService", mysql.Name)
 	// 클러스터로부터 서비스를 가져온다
 	mysqlSvc := &corev1.Service{}
 	if err := r.client.Get(context.TODO(), getServiceName(mysql), mysqlSvc); err != nil {
@@ -26,15 +27,24 @@ func (r *ReconcileMySQL) syncService(mysql *mysqlv1alpha1.MySQL) error {
 		klog.Infof("[%s] Could not find mysql service. Create a new one", mysql.Name)
 		return r.createService(mysql)
 	}
-	return nil
+	// 원하는 서비스 객체를 만들어서 클러스터의 서비스와 비교하고, 다르면 갱신한다
+	desired, err := newService(mysql, r.scheme)
+	if err != nil {
+		return err
+	}
+	if !updateService(mysqlSvc, desired) {
+		return nil
+	}
+	klog.Infof("[%s] Update mysql service", mysql.Name)
+	return r.client.Update(context.TODO(), mysqlSvc)
 }
 
-// getReadServiceName 는 mysql에 대한 서비스 이름과 네임스페이스를 리턴한다
+// getServiceName 는 mysql에 대한 서비스 이름과 네임스페이스를 리턴한다
 func getServiceName(mysql *mysqlv1alpha1.MySQL) types.NamespacedName {
 	return types.NamespacedName{Namespace: mysql.Namespace, Name: mysql.Name}
 }
 
-// createReadService 는 새로운 서비스를 생성한다. 이미 서비스가 존재하는 경우 성공한다
+// createService 는 새로운 서비스를 생성한다. 이미 서비스가 존재하는 경우 성공한다
 func (r *ReconcileMySQL) createService(mysql *mysqlv1alpha1.MySQL) error {
 	// 서비스를 위한 객체를 생성한다
 	mysqlSvc, err := newService(mysql, r.scheme)
@@ -48,12 +58,14 @@ func (r *ReconcileMySQL) createService(mysql *mysqlv1alpha1.MySQL) error {
 	return nil
 }
 
-// newReadService 는 서비스를 위한 객체를 생성한다. 객체는 mysql 객체를 오너로 가진다
+// newService 는 서비스를 위한 객체를 생성한다. 객체는 mysql 객체를 오너로 가진다
 func newService(mysql *mysqlv1alpha1.MySQL, scheme *runtime.Scheme) (*corev1.Service, error) {
 	svc := &corev1.Service{
 		ObjectMeta: v1.ObjectMeta{
-			Name:      getServiceName(mysql).Name,
-			Namespace: getServiceName(mysql).Namespace,
+			Name:        getServiceName(mysql).Name,
+			Namespace:   getServiceName(mysql).Namespace,
+			Labels:      labelsForMySQL(mysql, componentService),
+			Annotations: annotationsForMySQL(mysql),
 		},
 		Spec: corev1.ServiceSpec{
 			Ports: []corev1.ServicePort{
@@ -62,9 +74,7 @@ func newService(mysql *mysqlv1alpha1.MySQL, scheme *runtime.Scheme) (*corev1.Ser
 					Port: 3306,
 				},
 			},
-			Selector: map[string]string{
-				"app": "mysql", // TODO
-			},
+			Selector:  selectorForMySQL(mysql),
 			ClusterIP: "None",
 		},
 	}
@@ -73,3 +83,18 @@ func newService(mysql *mysqlv1alpha1.MySQL, scheme *runtime.Scheme) (*corev1.Ser
 	}
 	return svc, nil
 }
+
+// updateService 는 live 서비스를 desired 서비스에 맞추고 변경이 있었는지 리턴한다
+// 포트는 desired 에 지정한 필드만 비교하고, 클러스터 IP 처럼 API 서버가 채워 넣는 필드는 그대로 둔다
+func updateService(live, desired *corev1.Service) bool {
+	changed := mergeMetadata(&live.ObjectMeta, &desired.ObjectMeta)
+	if !equality.Semantic.DeepDerivative(desired.Spec.Ports, live.Spec.Ports) {
+		live.Spec.Ports = desired.Spec.Ports
+		changed = true
+	}
+	if !equality.Semantic.DeepEqual(desired.Spec.Selector, live.Spec.Selector) {
+		live.Spec.Selector = desired.Spec.Selector
+		changed = true
+	}
+	return changed
+}
@@ -2,9 +2,13 @@ package mysql
 
 import (
 	"context"
+	"fmt"
+	"strings"
+
 	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
 	v1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -14,7 +18,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-// syncStatefulSet 는 mysql 스테이트풀셋이 없는 경우 생성한다
+// syncStatefulSet 는 mysql 스테이트풀셋이 없는 경우 생성하고, 있는 경우 스펙과 다른 부분을 갱신한다
+// 멤버의 수가 늘어나면 clone-mysql 초기화 컨테이너가 이전 멤버로부터 데이터를 복제해서 레플리카가 되고,
+// 줄어들면 제거될 멤버의 복제를 멈춘 다음 스테이트풀셋이 가장 큰 순번부터 제거한다. 파드 템플릿이 바뀌면 스테이트풀셋이 파드를 차례로 다시 시작한다
 func (r *ReconcileMySQL) syncStatefulSet(mysql *mysqlv1alpha1.MySQL) error {
 	klog.Infof("[%s] syncStatefulSet", mysql.Name)
 	// 클러스터로부터 스테이트풀셋을 가져온다
@@ -28,14 +34,311 @@ func (r *ReconcileMySQL) syncStatefulSet(mysql *mysqlv1alpha1.MySQL) error {
 		klog.Infof("[%s] Could not find mysql stateful set. Create a new one", mysql.Name)
 		return r.createStatefulSet(mysql)
 	}
-	return nil
+	// 볼륨 클레임 템플릿을 바꾸기 위해 삭제 중인 스테이트풀셋은 삭제가 끝난 뒤의 조정 루프에서 다시 만든다
+	if statefulSet.DeletionTimestamp != nil {
+		klog.Infof("[%s] Waiting for mysql stateful set to be deleted", mysql.Name)
+		return nil
+	}
+	// 데이터 볼륨의 크기가 커졌다면 볼륨 클레임을 늘리고, 모두 늘어나면 스테이트풀셋을 다시 만든다
+	if recreating, err := r.syncDataVolumeSize(mysql, statefulSet); err != nil || recreating {
+		return err
+	}
+	// 원하는 스테이트풀셋 객체를 만들어서 클러스터의 스테이트풀셋과 비교하고, 다르면 갱신한다
+	desired, err := newStatefulSet(mysql, r.scheme)
+	if err != nil {
+		return err
+	}
+	// 멤버의 수가 줄어든다면 스테이트풀셋이 멤버를 제거하기 전에 제거될 멤버를 확인하고 정리한다
+	if statefulSet.Spec.Replicas != nil && *desired.Spec.Replicas < *statefulSet.Spec.Replicas {
+		if err := r.prepareScaleDown(mysql, *statefulSet.Spec.Replicas, *desired.Spec.Replicas); err != nil {
+			return err
+		}
+	}
+	if !updateStatefulSet(statefulSet, desired) {
+		return nil
+	}
+	klog.Infof("[%s] Update mysql stateful set", mysql.Name)
+	return r.client.Update(context.TODO(), statefulSet)
 }
 
+// updateStatefulSet 는 live 스테이트풀셋을 desired 스테이트풀셋에 맞추고 변경이 있었는지 리턴한다
+// 파드 템플릿은 equalPodTemplate 으로 비교하기 때문에 API 서버가 채워 넣은 기본값으로 인해 매번 갱신하지 않는다
+// 셀렉터, 서비스 이름, 볼륨 클레임 템플릿은 바꿀 수 없는 필드이므로 비교하지 않는다. 데이터 볼륨의 크기는 syncDataVolumeSize 가 맞춘다
+func updateStatefulSet(live, desired *v1.StatefulSet) bool {
+	keepSelectorLabels(live, desired)
+	changed := mergeMetadata(&live.ObjectMeta, &desired.ObjectMeta)
+	if !equality.Semantic.DeepEqual(desired.Spec.Replicas, live.Spec.Replicas) {
+		live.Spec.Replicas = desired.Spec.Replicas
+		changed = true
+	}
+	if !equality.Semantic.DeepDerivative(desired.Spec.UpdateStrategy, live.Spec.UpdateStrategy) {
+		live.Spec.UpdateStrategy = desired.Spec.UpdateStrategy
+		changed = true
+	}
+	if !equalPodTemplate(&desired.Spec.Template, &live.Spec.Template) {
+		live.Spec.Template = desired.Spec.Template
+		changed = true
+	}
+	return changed
+}
+
+// keepSelectorLabels 는 live 스테이트풀셋의 셀렉터 레이블을 desired 의 파드 템플릿에 더한다
+// 셀렉터는 바꿀 수 없으므로 이전 버전의 오퍼레이터가 app: mysql 셀렉터로 만든 스테이트풀셋은 그 셀렉터를 계속 사용한다.
+// 파드 템플릿의 레이블이 셀렉터와 맞지 않으면 API 서버가 갱신을 거부하므로 템플릿에 셀렉터의 레이블을 남겨둔다
+func keepSelectorLabels(live, desired *v1.StatefulSet) {
+	if live.Spec.Selector == nil || len(live.Spec.Selector.MatchLabels) == 0 {
+		return
+	}
+	if desired.Spec.Template.Labels == nil {
+		desired.Spec.Template.Labels = map[string]string{}
+	}
+	for key, value := range live.Spec.Selector.MatchLabels {
+		desired.Spec.Template.Labels[key] = value
+	}
+}
+
+// configHashAnnotation 는 파드 템플릿에 mysql 설정의 해시를 기록하는 어노테이션이다
+// 설정이 바뀌면 파드 템플릿이 바뀌므로 스테이트풀셋이 파드를 차례로 다시 시작해서 새로운 설정을 읽게 된다
+const configHashAnnotation = "mysql.woohhan.com/config-hash"
+
 // getStatefulSetName 는 mysql 스테이트풀셋에 대한 이름을 리턴한다
 func getStatefulSetName(mysql *mysqlv1alpha1.MySQL) types.NamespacedName {
 	return types.NamespacedName{Namespace: mysql.Namespace, Name: mysql.Name}
 }
 
+// getPeerDomain 는 스테이트풀셋의 각 멤버가 가지는 DNS 이름의 도메인 부분을 리턴한다
+// 멤버의 DNS 이름은 헤드리스 서비스에 의해 <파드 이름>.<서비스 이름>.<네임스페이스>.svc 가 된다
+func getPeerDomain(mysql *mysqlv1alpha1.MySQL) string {
+	return fmt.Sprintf("%s.%s.svc", getServiceName(mysql).Name, mysql.Namespace)
+}
+
+// getPodName 는 ordinal 순번을 가진 멤버의 파드 이름을 리턴한다
+func getPodName(mysql *mysqlv1alpha1.MySQL, ordinal int) string {
+	return fmt.Sprintf("%s-%d", getStatefulSetName(mysql).Name, ordinal)
+}
+
+// getPeerHost 는 ordinal 순번을 가진 멤버의 DNS 이름을 리턴한다
+func getPeerHost(mysql *mysqlv1alpha1.MySQL, ordinal int) string {
+	return getMemberHost(mysql, getPodName(mysql, ordinal))
+}
+
+// getMemberHost 는 podName 멤버의 DNS 이름을 리턴한다
+func getMemberHost(mysql *mysqlv1alpha1.MySQL, podName string) string {
+	return podName + "." + getPeerDomain(mysql)
+}
+
+// getPrimaryPodName 는 프라이머리 파드의 이름을 리턴한다
+// 프라이머리는 오퍼레이터가 상태에 기록하며, 기록이 없다면 처음 만들어진 0번 멤버이다
+func getPrimaryPodName(mysql *mysqlv1alpha1.MySQL) string {
+	if mysql.Status.CurrentPrimary != "" {
+		return mysql.Status.CurrentPrimary
+	}
+	return getPodName(mysql, 0)
+}
+
+// newPeerEnv 는 부트스트랩 스크립트가 다른 멤버를 찾을 때 사용하는 환경변수를 리턴한다
+// 프라이머리는 바뀔 수 있으므로 환경변수 대신 컨피그맵의 primary 파일에서 읽는다
+func newPeerEnv(mysql *mysqlv1alpha1.MySQL) []corev1.EnvVar {
+	return []corev1.EnvVar{
+		{
+			Name:  "PEER_DOMAIN",
+			Value: getPeerDomain(mysql),
+		},
+	}
+}
+
+// getMySQLResources 는 mysql 컨테이너의 리소스 요청과 제한을 리턴한다
+func getMySQLResources(mysql *mysqlv1alpha1.MySQL) corev1.ResourceRequirements {
+	if len(mysql.Spec.Resources.Requests) != 0 || len(mysql.Spec.Resources.Limits) != 0 {
+		return mysql.Spec.Resources
+	}
+	return corev1.ResourceRequirements{
+		Requests: map[corev1.ResourceName]resource.Quantity{
+			corev1.ResourceCPU:    resource.MustParse("500m"),
+			corev1.ResourceMemory: resource.MustParse("1Gi")},
+	}
+}
+
+// getBackupResources 는 xtrabackup 사이드카의 리소스 요청과 제한을 리턴한다
+func getBackupResources(mysql *mysqlv1alpha1.MySQL) corev1.ResourceRequirements {
+	if sidecar := mysql.Spec.BackupSidecar; sidecar != nil && (len(sidecar.Resources.Requests) != 0 || len(sidecar.Resources.Limits) != 0) {
+		return sidecar.Resources
+	}
+	return corev1.ResourceRequirements{
+		Requests: map[corev1.ResourceName]resource.Quantity{
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: resource.MustParse("100Mi")},
+	}
+}
+
+// equalPodTemplate 는 두 파드 템플릿에서 desired 가 지정하는 필드가 같은지 리턴한다
+// API 서버가 기본값을 채우는 필드는 DeepDerivative 로 비교하고, 레이블과 컨테이너의 목록 필드는 정확히 비교해서
+// 사용자가 지우거나 기본값으로 되돌린 항목도 찾아낸다. 어노테이션은 kubectl rollout restart 가 붙이는 값을 지우지 않도록 정확히 비교하지 않는다
+func equalPodTemplate(desired, live *corev1.PodTemplateSpec) bool {
+	return equality.Semantic.DeepDerivative(*desired, *live) &&
+		equality.Semantic.DeepEqual(desired.Labels, live.Labels) &&
+		equalVolumeNames(desired.Spec.Volumes, live.Spec.Volumes) &&
+		equalContainers(desired.Spec.InitContainers, live.Spec.InitContainers) &&
+		equalContainers(desired.Spec.Containers, live.Spec.Containers) &&
+		equalScheduling(&desired.Spec, &live.Spec) &&
+		equalResources(&desired.Spec, &live.Spec)
+}
+
+// equalVolumeNames 는 두 파드 스펙의 볼륨 목록이 같은 이름의 볼륨을 같은 순서로 가지는지 리턴한다
+// 각 볼륨의 내용은 API 서버가 기본값(defaultMode 등)을 채우므로 DeepDerivative 로 비교한다
+func equalVolumeNames(desired, live []corev1.Volume) bool {
+	if len(desired) != len(live) {
+		return false
+	}
+	for i := range desired {
+		if desired[i].Name != live[i].Name {
+			return false
+		}
+	}
+	return true
+}
+
+// equalContainers 는 같은 이름을 가진 컨테이너끼리 명령, 환경변수, 볼륨 마운트, 포트와 이미지 풀 정책을 정확히 비교한다
+func equalContainers(desired, live []corev1.Container) bool {
+	if len(desired) != len(live) {
+		return false
+	}
+	for i := range desired {
+		if desired[i].Name != live[i].Name ||
+			!equality.Semantic.DeepEqual(desired[i].Command, live[i].Command) ||
+			!equality.Semantic.DeepEqual(desired[i].Args, live[i].Args) ||
+			!equality.Semantic.DeepEqual(desired[i].Env, live[i].Env) ||
+			!equality.Semantic.DeepEqual(desired[i].VolumeMounts, live[i].VolumeMounts) ||
+			!equalPorts(desired[i].Ports, live[i].Ports) ||
+			getImagePullPolicy(&desired[i]) != getImagePullPolicy(&live[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// equalPorts 는 두 컨테이너의 포트 목록이 같은지 리턴한다. 프로토콜을 지정하지 않으면 API 서버가 TCP 로 채운다
+func equalPorts(desired, live []corev1.ContainerPort) bool {
+	if len(desired) != len(live) {
+		return false
+	}
+	for i := range desired {
+		if getPortWithProtocol(desired[i]) != getPortWithProtocol(live[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// getPortWithProtocol 는 프로토콜을 지정하지 않은 포트에 API 서버가 채우는 TCP 를 채워서 리턴한다
+func getPortWithProtocol(port corev1.ContainerPort) corev1.ContainerPort {
+	if port.Protocol == "" {
+		port.Protocol = corev1.ProtocolTCP
+	}
+	return port
+}
+
+// getImagePullPolicy 는 컨테이너의 이미지 풀 정책을 리턴한다. 지정하지 않았다면 API 서버가 채우는 기본값을 리턴한다
+// API 서버는 이미지의 태그가 latest 이거나 태그와 다이제스트가 모두 없으면 Always, 그 외에는 IfNotPresent 를 채운다
+func getImagePullPolicy(container *corev1.Container) corev1.PullPolicy {
+	if container.ImagePullPolicy != "" {
+		return container.ImagePullPolicy
+	}
+	image := container.Image
+	if strings.Contains(image, "@") {
+		return corev1.PullIfNotPresent
+	}
+	tag := ""
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		tag = image[i+1:]
+	}
+	if tag == "" || tag == "latest" {
+		return corev1.PullAlways
+	}
+	return corev1.PullIfNotPresent
+}
+
+// equalResources 는 두 파드 스펙의 컨테이너와 초기화 컨테이너의 리소스 요청과 제한이 같은지 리턴한다
+// API 서버는 파드 템플릿의 리소스에 기본값을 채우지 않으므로 정확히 비교해서 사용자가 지운 요청과 제한도 찾아낸다
+func equalResources(desired, live *corev1.PodSpec) bool {
+	return equalContainerResources(desired.InitContainers, live.InitContainers) &&
+		equalContainerResources(desired.Containers, live.Containers)
+}
+
+// equalContainerResources 는 같은 이름을 가진 컨테이너끼리 리소스 요청과 제한을 비교한다
+func equalContainerResources(desired, live []corev1.Container) bool {
+	if len(desired) != len(live) {
+		return false
+	}
+	for i := range desired {
+		if desired[i].Name != live[i].Name || !equality.Semantic.DeepEqual(desired[i].Resources, live[i].Resources) {
+			return false
+		}
+	}
+	return true
+}
+
+// 데이터 볼륨 클레임의 기본값
+const (
+	dataVolumeName        = "data"
+	defaultDataVolumeSize = "2Gi"
+)
+
+// newDataVolumeClaim 는 각 멤버의 데이터 볼륨을 위한 볼륨 클레임 템플릿을 생성한다
+// 스테이트풀셋은 이 템플릿으로 data-<파드 이름> 이라는 이름의 볼륨 클레임을 멤버마다 만든다
+func newDataVolumeClaim(mysql *mysqlv1alpha1.MySQL) corev1.PersistentVolumeClaim {
+	storage := mysql.Spec.Storage
+	if storage == nil {
+		storage = &mysqlv1alpha1.StorageSpec{}
+	}
+	accessModes := storage.AccessModes
+	if len(accessModes) == 0 {
+		accessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	}
+	return corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        dataVolumeName,
+			Labels:      storage.Labels,
+			Annotations: storage.Annotations,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      accessModes,
+			StorageClassName: storage.StorageClassName,
+			Resources: corev1.ResourceRequirements{
+				Requests: map[corev1.ResourceName]resource.Quantity{
+					corev1.ResourceStorage: getDataVolumeSize(mysql)},
+			},
+		},
+	}
+}
+
+// getClonePositionScript 는 복제(clone)한 데이터가 프라이머리의 어디부터 복제해야 하는지 change_master_to.sql.in 에 기록하는 스크립트를 리턴한다
+// GTID 모드에서는 백업의 GTID 집합만 기록하면 되므로 바이너리 로그의 위치를 해석하지 않는다
+func getClonePositionScript(mysql *mysqlv1alpha1.MySQL) string {
+	if mysql.Spec.GetReplicationMode() == mysqlv1alpha1.ReplicationModeGTID {
+		return `# Determine the GTID set of cloned data, if any.
+if [[ -f xtrabackup_binlog_info ]]; then
+  # The third column is the GTID set of the backup, which may span several lines.
+  gtid_purged=$(tr -d '\n' < xtrabackup_binlog_info | cut -f3)
+  rm -f xtrabackup_binlog_info xtrabackup_slave_info
+  echo "RESET MASTER; SET GLOBAL gtid_purged='${gtid_purged}'; CHANGE MASTER TO MASTER_AUTO_POSITION=1" > change_master_to.sql.in
+fi`
+	}
+	return `# Determine binlog position of cloned data, if any.
+if [[ -f xtrabackup_slave_info && "x$(<xtrabackup_slave_info)" != "x" ]]; then
+  # XtraBackup already generated a partial "CHANGE MASTER TO" query
+  # because we're cloning from an existing slave. (Need to remove the tailing semicolon!)
+  cat xtrabackup_slave_info | sed -E 's/;$//g' > change_master_to.sql.in
+  # Ignore xtrabackup_binlog_info in this case (it's useless).
+  rm -f xtrabackup_slave_info xtrabackup_binlog_info
+elif [[ -f xtrabackup_binlog_info ]]; then
+  # We're cloning directly from master. Parse binlog position.
+  [[ ` + "`" + `cat xtrabackup_binlog_info` + "`" + ` =~ ^(.*?)[[:space:]]+(.*?)$ ]] || exit 1
+  rm -f xtrabackup_binlog_info xtrabackup_slave_info
+  echo "CHANGE MASTER TO MASTER_LOG_FILE='${BASH_REMATCH[1]}', MASTER_LOG_POS=${BASH_REMATCH[2]}" > change_master_to.sql.in
+fi`
+}
+
 // createStatefulSet 는 새로운 스테이트풀셋을 생성한다.
 func (r *ReconcileMySQL) createStatefulSet(mysql *mysqlv1alpha1.MySQL) error {
 	// 객체를 생성한다
@@ -54,23 +357,28 @@ func (r *ReconcileMySQL) createStatefulSet(mysql *mysqlv1alpha1.MySQL) error {
 // 복잡해 보이지만 이 내용은 관리할 애플리케이션에 실행할 내용이기 때문에 애플리케이션에 따라 달라진다
 // 이 내용은 MySQL에서 작업을 수행하기 위한 내용이기 때문에 만약 다른 애플리케이션을 위한 오퍼레이터를 만든다면 그 애플리케이션을 위한 코드가 들어가야 한다
 func newStatefulSet(mysql *mysqlv1alpha1.MySQL, scheme *runtime.Scheme) (*v1.StatefulSet, error) {
-	replicas := int32(2) // TODO
+	replicas := mysql.Spec.GetReplicas()
 	statefulSet := &v1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      getStatefulSetName(mysql).Name,
-			Namespace: getStatefulSetName(mysql).Namespace,
+			Name:        getStatefulSetName(mysql).Name,
+			Namespace:   getStatefulSetName(mysql).Namespace,
+			Labels:      labelsForMySQL(mysql, componentDatabase),
+			Annotations: annotationsForMySQL(mysql),
 		},
 		Spec: v1.StatefulSetSpec{
 			Replicas: &replicas,
+			// 파드 템플릿이 바뀌면 가장 큰 순번의 멤버부터 하나씩 교체하고, 교체한 멤버가 준비된 다음에 다음 멤버를 교체한다
+			UpdateStrategy: v1.StatefulSetUpdateStrategy{
+				Type: v1.RollingUpdateStatefulSetStrategyType,
+			},
 			Selector: &metav1.LabelSelector{
-				MatchLabels: map[string]string{
-					"app": "mysql", // TODO
-				},
+				MatchLabels: selectorForMySQL(mysql),
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: map[string]string{
-						"app": "mysql",
+					Labels: labelsForMySQL(mysql, componentDatabase),
+					Annotations: map[string]string{
+						configHashAnnotation: getConfigHash(mysql),
 					},
 				},
 				Spec: corev1.PodSpec{
@@ -86,7 +394,7 @@ func newStatefulSet(mysql *mysqlv1alpha1.MySQL, scheme *runtime.Scheme) (*v1.Sta
 							VolumeSource: corev1.VolumeSource{
 								ConfigMap: &corev1.ConfigMapVolumeSource{
 									LocalObjectReference: corev1.LocalObjectReference{
-										Name: "mysql",
+										Name: getConfigMapName(mysql).Name,
 									},
 								},
 							},
@@ -94,8 +402,9 @@ func newStatefulSet(mysql *mysqlv1alpha1.MySQL, scheme *runtime.Scheme) (*v1.Sta
 					},
 					InitContainers: []corev1.Container{
 						{
-							Name:  "init-mysql",
-							Image: "mysql:5.7",
+							Name:            "init-mysql",
+							Image:           getMySQLImage(mysql),
+							ImagePullPolicy: mysql.Spec.ImagePullPolicy,
 							Command: []string{
 								"bash",
 								"-c",
@@ -107,10 +416,11 @@ echo [mysqld] > /mnt/conf.d/server-id.cnf
 # Add an offset to avoid reserved server-id=0 value.
 echo server-id=$((100 + $ordinal)) >> /mnt/conf.d/server-id.cnf
 # Copy appropriate conf.d files from config-map to emptyDir.
-if [[ $ordinal -eq 0 ]]; then
-  cp /mnt/config-map/master.cnf /mnt/conf.d/
+# The operator records the current primary in the config map.
+if [[ ` + "`" + `hostname` + "`" + ` == "$(</mnt/config-map/` + primaryKey + `)" ]]; then
+  cp /mnt/config-map/` + primaryConfigKey + ` /mnt/conf.d/
 else
-  cp /mnt/config-map/slave.cnf /mnt/conf.d/
+  cp /mnt/config-map/` + replicaConfigKey + ` /mnt/conf.d/
 fi`,
 							},
 							VolumeMounts: []corev1.VolumeMount{
@@ -125,26 +435,27 @@ fi`,
 							},
 						},
 						{
-							Name:  "clone-mysql",
-							Image: "gcr.io/google-samples/xtrabackup:1.0",
+							Name:            "clone-mysql",
+							Image:           getBackupImage(mysql),
+							ImagePullPolicy: mysql.Spec.ImagePullPolicy,
 							Command: []string{
 								"bash",
 								"-c",
 								`set -ex
 # Skip the clone if data already exists.
 [[ -d /var/lib/mysql/mysql ]] && exit 0
-# Skip the clone on master (ordinal index 0).
-[[ ` + "`" + `hostname` + "`" + ` =~ -([0-9]+)$ ]] || exit 1
-ordinal=${BASH_REMATCH[1]}
-[[ $ordinal -eq 0 ]] && exit 0
-# Clone data from previous peer.
-ncat --recv-only mysql-$(($ordinal-1)).mysql 3307 | xbstream -x -C /var/lib/mysql
+# Skip the clone on the primary.
+primary=$(</mnt/config-map/` + primaryKey + `)
+[[ ` + "`" + `hostname` + "`" + ` == "$primary" ]] && exit 0
+# Clone data from the primary. After a failover a previous peer may no longer be replicating.
+ncat --recv-only ${primary}.${PEER_DOMAIN} 3307 | xbstream -x -C /var/lib/mysql
 # Prepare the backup.
 xtrabackup --prepare --target-dir=/var/lib/mysql`,
 							},
+							Env: newPeerEnv(mysql),
 							VolumeMounts: []corev1.VolumeMount{
 								{
-									Name:      "data",
+									Name:      dataVolumeName,
 									MountPath: "/var/lib/mysql",
 									SubPath:   "mysql",
 								},
@@ -152,27 +463,23 @@ xtrabackup --prepare --target-dir=/var/lib/mysql`,
 									Name:      "conf",
 									MountPath: "/etc/mysql/conf.d",
 								},
+								{
+									Name:      "config-map",
+									MountPath: "/mnt/config-map",
+								},
 							},
 						},
 					},
 					Containers: []corev1.Container{
 						{
-							Name:  "mysql",
-							Image: "mysql:5.7",
-							Env: []corev1.EnvVar{
-								{
-									Name:  "MYSQL_ALLOW_EMPTY_PASSWORD",
-									Value: "1",
-								},
-							},
-							Resources: corev1.ResourceRequirements{
-								Requests: map[corev1.ResourceName]resource.Quantity{
-									corev1.ResourceCPU:    resource.MustParse("500m"),
-									corev1.ResourceMemory: resource.MustParse("1Gi")},
-							},
+							Name:            mysqlContainerName,
+							Image:           getMySQLImage(mysql),
+							ImagePullPolicy: mysql.Spec.ImagePullPolicy,
+							Env:             append([]corev1.EnvVar{newRootPasswordEnv(mysql)}, newSystemUserEnv(mysql, monitorUser)...),
+							Resources:       getMySQLResources(mysql),
 							VolumeMounts: []corev1.VolumeMount{
 								{
-									Name:      "data",
+									Name:      dataVolumeName,
 									MountPath: "/var/lib/mysql",
 									SubPath:   "mysql",
 								},
@@ -185,7 +492,7 @@ xtrabackup --prepare --target-dir=/var/lib/mysql`,
 								Handler: corev1.Handler{
 									Exec: &corev1.ExecAction{
 										Command: []string{
-											"mysqladmin", "ping",
+											"bash", "-c", `mysqladmin ping -h 127.0.0.1 -u"${MONITOR_USER}" -p"${MONITOR_PASSWORD}"`,
 										},
 									},
 								},
@@ -197,7 +504,7 @@ xtrabackup --prepare --target-dir=/var/lib/mysql`,
 								Handler: corev1.Handler{
 									Exec: &corev1.ExecAction{
 										Command: []string{
-											"mysql", "-h", "127.0.0.1", "-e", "SELECT 1",
+											"bash", "-c", `mysql -h 127.0.0.1 -u"${MONITOR_USER}" -p"${MONITOR_PASSWORD}" -e "SELECT 1"`,
 										},
 									},
 								},
@@ -213,47 +520,37 @@ xtrabackup --prepare --target-dir=/var/lib/mysql`,
 							},
 						},
 						{
-							Name:  "xtrabackup",
-							Image: "gcr.io/google-samples/xtrabackup:1.0",
+							Name:            "xtrabackup",
+							Image:           getBackupImage(mysql),
+							ImagePullPolicy: mysql.Spec.ImagePullPolicy,
 							Command: []string{
 								"bash", "-c",
 								`set -ex
 cd /var/lib/mysql
 
-# Determine binlog position of cloned data, if any.
-if [[ -f xtrabackup_slave_info && "x$(<xtrabackup_slave_info)" != "x" ]]; then
-  # XtraBackup already generated a partial "CHANGE MASTER TO" query
-  # because we're cloning from an existing slave. (Need to remove the tailing semicolon!)
-  cat xtrabackup_slave_info | sed -E 's/;$//g' > change_master_to.sql.in
-  # Ignore xtrabackup_binlog_info in this case (it's useless).
-  rm -f xtrabackup_slave_info xtrabackup_binlog_info
-elif [[ -f xtrabackup_binlog_info ]]; then
-  # We're cloning directly from master. Parse binlog position.
-  [[ ` + "`" + `cat xtrabackup_binlog_info` + "`" + ` =~ ^(.*?)[[:space:]]+(.*?)$ ]] || exit 1
-  rm -f xtrabackup_binlog_info xtrabackup_slave_info
-  echo "CHANGE MASTER TO MASTER_LOG_FILE='${BASH_REMATCH[1]}', MASTER_LOG_POS=${BASH_REMATCH[2]}" > change_master_to.sql.in
-fi
+` + getClonePositionScript(mysql) + `
 
 # Check if we need to complete a clone by starting replication.
 if [[ -f change_master_to.sql.in ]]; then
   echo "Waiting for mysqld to be ready (accepting connections)"
-  until mysql -h 127.0.0.1 -e "SELECT 1"; do sleep 1; done
+  until mysql -h 127.0.0.1 -uroot -p"${MYSQL_ROOT_PASSWORD}" -e "SELECT 1"; do sleep 1; done
 
   echo "Initializing replication from clone position"
-  mysql -h 127.0.0.1 \
+  mysql -h 127.0.0.1 -uroot -p"${MYSQL_ROOT_PASSWORD}" \
 -e "$(<change_master_to.sql.in), \
-MASTER_HOST='mysql-0.mysql', \
-MASTER_USER='root', \
-MASTER_PASSWORD='', \
-MASTER_CONNECT_RETRY=10; \
+MASTER_HOST='$(</mnt/config-map/` + primaryKey + `).${PEER_DOMAIN}', \
+MASTER_USER='${REPLICATION_USER}', \
+MASTER_PASSWORD='${REPLICATION_PASSWORD}', \
+MASTER_CONNECT_RETRY=10` + getPrimaryAuthOptions(mysql) + `; \
 START SLAVE;" || exit 1
   # In case of container restart, attempt this at-most-once.
   mv change_master_to.sql.in change_master_to.sql.orig
 fi
 
 # Start a server to send backups when requested by peers.
-exec ncat --listen --keep-open --send-only --max-conns=1 3307 -c "xtrabackup --backup --slave-info --stream=xbstream --host=127.0.0.1 --user=root"`,
+exec ncat --listen --keep-open --send-only --max-conns=1 3307 -c "xtrabackup --backup --slave-info --stream=xbstream --host=127.0.0.1 --user=${BACKUP_USER} --password=${BACKUP_PASSWORD}"`,
 							},
+							Env: append(append(newPeerEnv(mysql), newRootPasswordEnv(mysql)), newSystemUserEnv(mysql, replicationUser, backupUser)...),
 							Ports: []corev1.ContainerPort{
 								{
 									Name:          "xtrabackup",
@@ -262,7 +559,7 @@ exec ncat --listen --keep-open --send-only --max-conns=1 3307 -c "xtrabackup --b
 							},
 							VolumeMounts: []corev1.VolumeMount{
 								{
-									Name:      "data",
+									Name:      dataVolumeName,
 									MountPath: "/var/lib/mysql",
 									SubPath:   "mysql",
 								},
@@ -270,35 +567,24 @@ exec ncat --listen --keep-open --send-only --max-conns=1 3307 -c "xtrabackup --b
 									Name:      "conf",
 									MountPath: "/etc/mysql/conf.d",
 								},
+								{
+									Name:      "config-map",
+									MountPath: "/mnt/config-map",
+								},
 							},
-							Resources: corev1.ResourceRequirements{
-								Requests: map[corev1.ResourceName]resource.Quantity{
-									corev1.ResourceCPU:    resource.MustParse("100m"),
-									corev1.ResourceMemory: resource.MustParse("100Mi")},
-							},
+							Resources: getBackupResources(mysql),
 						},
 					},
 				},
 			},
 			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
-				{
-					ObjectMeta: metav1.ObjectMeta{
-						Name: "data",
-					},
-					Spec: corev1.PersistentVolumeClaimSpec{
-						AccessModes: []corev1.PersistentVolumeAccessMode{
-							"ReadWriteOnce",
-						},
-						Resources: corev1.ResourceRequirements{
-							Requests: map[corev1.ResourceName]resource.Quantity{
-								corev1.ResourceStorage: resource.MustParse("2Gi")},
-						},
-					},
-				},
+				newDataVolumeClaim(mysql),
 			},
-			ServiceName: "mysql", // TODO
+			ServiceName: getServiceName(mysql).Name,
 		},
 	}
+	applyPodTemplate(mysql, &statefulSet.Spec.Template.Spec)
+	applyGroupReplication(mysql, &statefulSet.Spec.Template.Spec)
 	if err := controllerutil.SetControllerReference(mysql, statefulSet, scheme); err != nil {
 		return nil, err
 	}
@@ -0,0 +1,158 @@
+package mysql
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestUpdateStatefulSet(t *testing.T) {
+	newDesired := func() *v1.StatefulSet {
+		replicas := int32(3)
+		return &v1.StatefulSet{
+			Spec: v1.StatefulSetSpec{
+				Replicas: &replicas,
+				Template: corev1.PodTemplateSpec{
+					ObjectMeta: metav1.ObjectMeta{
+						Labels: map[string]string{labelName: appName, labelComponent: componentDatabase},
+					},
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{
+							{
+								Name:  mysqlContainerName,
+								Image: "mysql:5.7",
+								Env:   []corev1.EnvVar{{Name: "PEER_DOMAIN", Value: "mysql.default.svc"}},
+								Ports: []corev1.ContainerPort{{Name: "mysql", ContainerPort: 3306}},
+								Resources: corev1.ResourceRequirements{
+									Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+									Limits:   corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+								},
+							},
+						},
+						Tolerations: []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}},
+					},
+				},
+			},
+		}
+	}
+	// newLive 는 API 서버가 기본값을 채운 것처럼 desired 를 복사한다
+	newLive := func() *v1.StatefulSet {
+		live := newDesired()
+		live.Spec.Template.Spec.RestartPolicy = corev1.RestartPolicyAlways
+		live.Spec.Template.Spec.DNSPolicy = corev1.DNSClusterFirst
+		live.Spec.Template.Spec.Containers[0].TerminationMessagePath = corev1.TerminationMessagePathDefault
+		live.Spec.Template.Spec.Containers[0].ImagePullPolicy = corev1.PullIfNotPresent
+		live.Spec.Template.Spec.Containers[0].Ports[0].Protocol = corev1.ProtocolTCP
+		return live
+	}
+
+	tests := []struct {
+		name   string
+		modify func(desired *v1.StatefulSet)
+		want   bool
+	}{
+		{
+			name:   "fields defaulted by the API server",
+			modify: func(desired *v1.StatefulSet) {},
+			want:   false,
+		},
+		{
+			name: "replicas",
+			modify: func(desired *v1.StatefulSet) {
+				replicas := int32(5)
+				desired.Spec.Replicas = &replicas
+			},
+			want: true,
+		},
+		{
+			name: "image",
+			modify: func(desired *v1.StatefulSet) {
+				desired.Spec.Template.Spec.Containers[0].Image = "mysql:8.0"
+			},
+			want: true,
+		},
+		{
+			name: "removed limits",
+			modify: func(desired *v1.StatefulSet) {
+				desired.Spec.Template.Spec.Containers[0].Resources.Limits = nil
+			},
+			want: true,
+		},
+		{
+			name: "removed env var",
+			modify: func(desired *v1.StatefulSet) {
+				desired.Spec.Template.Spec.Containers[0].Env = nil
+			},
+			want: true,
+		},
+		{
+			name: "removed port",
+			modify: func(desired *v1.StatefulSet) {
+				desired.Spec.Template.Spec.Containers[0].Ports = nil
+			},
+			want: true,
+		},
+		{
+			name: "removed label",
+			modify: func(desired *v1.StatefulSet) {
+				delete(desired.Spec.Template.Labels, labelComponent)
+			},
+			want: true,
+		},
+		{
+			name: "image pull policy",
+			modify: func(desired *v1.StatefulSet) {
+				desired.Spec.Template.Spec.Containers[0].ImagePullPolicy = corev1.PullAlways
+			},
+			want: true,
+		},
+		{
+			name: "removed tolerations",
+			modify: func(desired *v1.StatefulSet) {
+				desired.Spec.Template.Spec.Tolerations = nil
+			},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			live := newLive()
+			desired := newDesired()
+			tt.modify(desired)
+			if got := updateStatefulSet(live, desired); got != tt.want {
+				t.Errorf("updateStatefulSet() = %v, want %v", got, tt.want)
+			}
+			if tt.want && updateStatefulSet(live, desired) {
+				t.Errorf("updateStatefulSet() reports a change again after the update")
+			}
+		})
+	}
+}
+
+func TestUpdateStatefulSetKeepsLegacySelector(t *testing.T) {
+	legacy := map[string]string{"app": "mysql"}
+	live := &v1.StatefulSet{
+		Spec: v1.StatefulSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: legacy},
+			Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "mysql"}}},
+		},
+	}
+	desired := &v1.StatefulSet{
+		Spec: v1.StatefulSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{labelName: appName, labelInstance: "mysql"}},
+			Template: corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{labelName: appName, labelInstance: "mysql"}}},
+		},
+	}
+	if !updateStatefulSet(live, desired) {
+		t.Fatalf("updateStatefulSet() = false, want true")
+	}
+	if live.Spec.Template.Labels["app"] != "mysql" || live.Spec.Template.Labels[labelInstance] != "mysql" {
+		t.Errorf("template labels = %v, want both the legacy selector and the instance labels", live.Spec.Template.Labels)
+	}
+	if live.Spec.Selector.MatchLabels["app"] != "mysql" || len(live.Spec.Selector.MatchLabels) != 1 {
+		t.Errorf("selector = %v, want the live selector unchanged", live.Spec.Selector.MatchLabels)
+	}
+}
@@ -0,0 +1,254 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// 컨디션의 원인(reason)
+const (
+	reasonAllMembersReady   status.ConditionReason = "AllMembersReady"
+	reasonMembersNotReady   status.ConditionReason = "MembersNotReady"
+	reasonRollingUpdate     status.ConditionReason = "RollingUpdate"
+	reasonScaling           status.ConditionReason = "Scaling"
+	reasonUpToDate          status.ConditionReason = "UpToDate"
+	reasonReplicasReady     status.ConditionReason = "ReplicasReady"
+	reasonReplicasNotReady  status.ConditionReason = "ReplicasNotReady"
+	reasonPrimaryNotReady   status.ConditionReason = "PrimaryNotReady"
+	reasonNoReplicas        status.ConditionReason = "NoReplicas"
+	reasonStatefulSetAbsent status.ConditionReason = "StatefulSetNotFound"
+	reasonExpandingVolumes  status.ConditionReason = "ExpandingVolumes"
+	reasonRecreating        status.ConditionReason = "RecreatingStatefulSet"
+)
+
+// updateStatus 는 스테이트풀셋과 파드의 상태를 관찰해서 MySQL 객체의 상태를 갱신한다
+// syncErr 는 이번 조정 루프에서 스펙을 반영하다 발생한 에러이며, 반영할 수 없는 스펙(specError)이면 Failed 상태가 된다
+// 업데이트 충돌과 같은 일시적인 에러는 다시 시도하면 해결되므로 단계(phase)와 컨디션을 바꾸지 않는다
+func (r *ReconcileMySQL) updateStatus(mysql *mysqlv1alpha1.MySQL, syncErr error) error {
+	klog.Infof("[%s] updateStatus", mysql.Name)
+	newStatus := mysql.Status.DeepCopy()
+
+	statefulSet := &v1.StatefulSet{}
+	if err := r.client.Get(context.TODO(), getStatefulSetName(mysql), statefulSet); err != nil {
+		if !errors.IsNotFound(err) {
+			return err
+		}
+		statefulSet = nil
+	}
+	pods, err := r.listPods(mysql)
+	if err != nil {
+		return err
+	}
+	claims, err := r.listDataVolumeClaims(mysql)
+	if err != nil {
+		return err
+	}
+	observeStatus(mysql, newStatus, statefulSet, pods, claims, syncErr)
+	if syncErr == nil {
+		r.observeSemiSync(mysql, newStatus, pods)
+		r.observeGroupReplication(mysql, newStatus, pods)
+		r.observeMembers(mysql, newStatus, pods)
+		r.observeSwitchover(mysql, newStatus, pods)
+	}
+
+	// 상태가 바뀌지 않았다면 갱신하지 않는다. 상태를 갱신하면 다시 조정 루프에 진입하기 때문이다
+	if equality.Semantic.DeepEqual(&mysql.Status, newStatus) {
+		return nil
+	}
+	mysql.Status = *newStatus
+	return r.client.Status().Update(context.TODO(), mysql)
+}
+
+// listPods 는 mysql 객체가 소유한 파드의 목록을 가져온다
+func (r *ReconcileMySQL) listPods(mysql *mysqlv1alpha1.MySQL) ([]corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	if err := r.client.List(context.TODO(), podList,
+		client.InNamespace(mysql.Namespace), client.MatchingLabels(selectorForMySQL(mysql))); err != nil {
+		return nil, err
+	}
+	return podList.Items, nil
+}
+
+// observeStatus 는 관찰한 스테이트풀셋과 파드, 데이터 볼륨 클레임을 이용해서 newStatus 를 채운다
+func observeStatus(mysql *mysqlv1alpha1.MySQL, newStatus *mysqlv1alpha1.MySQLStatus, statefulSet *v1.StatefulSet,
+	pods []corev1.Pod, claims []corev1.PersistentVolumeClaim, syncErr error) {
+	replicas := mysql.Spec.GetReplicas()
+	primaryName := getPrimaryPodName(mysql)
+
+	// 준비된 멤버와 프라이머리의 상태를 센다
+	readyReplicas := int32(0)
+	primaryReady := false
+	for i := range pods {
+		if !isPodReady(&pods[i]) {
+			continue
+		}
+		readyReplicas++
+		if pods[i].Name == primaryName {
+			primaryReady = true
+		}
+	}
+	newStatus.ReadyReplicas = readyReplicas
+	newStatus.CurrentPrimary = primaryName
+	// 페일오버 타임아웃을 재기 위해 프라이머리가 준비되지 않은 상태가 된 시각을 기록한다
+	// ReplicationHealthy 컨디션은 이미 False 이면 원인이 바뀌어도 전환 시각이 바뀌지 않으므로 따로 기록한다
+	switch {
+	case primaryReady:
+		newStatus.PrimaryNotReadySince = nil
+	case newStatus.PrimaryNotReadySince == nil:
+		now := metav1.Now()
+		newStatus.PrimaryNotReadySince = &now
+	}
+
+	// 스펙을 반영할 수 없다면 다른 상태와 관계없이 Failed 가 된다
+	if specErr, ok := syncErr.(*specError); ok {
+		newStatus.Phase = mysqlv1alpha1.MySQLPhaseFailed
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:    mysqlv1alpha1.ConditionReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  specErr.reason,
+			Message: specErr.Error(),
+		})
+		return
+	}
+	// 일시적인 에러로 반영을 마치지 못했다면 다시 시도할 때까지 이전의 단계와 컨디션을 유지한다
+	if syncErr != nil {
+		return
+	}
+	newStatus.ObservedGeneration = mysql.Generation
+	// 클러스터를 처음 만들 때의 복제 방법과 복제 구조를 기록한다
+	if newStatus.ReplicationMode == "" {
+		newStatus.ReplicationMode = mysql.Spec.GetReplicationMode()
+	}
+	if newStatus.Topology == "" {
+		newStatus.Topology = mysql.Spec.GetTopology()
+	}
+	// 데이터 볼륨 클레임 템플릿은 바꿀 수 없으므로 처음 반영한 설정을 기록해서 이후의 변경을 거부한다
+	if newStatus.DataVolumeClaimTemplate == nil {
+		newStatus.DataVolumeClaimTemplate = getDataVolumeClaimTemplateStatus(mysql)
+	}
+
+	// 스테이트풀셋이 원하는 멤버의 수와 파드 템플릿에 도달했는지 확인한다
+	progressing := status.Condition{
+		Type:   mysqlv1alpha1.ConditionProgressing,
+		Status: corev1.ConditionFalse,
+		Reason: reasonUpToDate,
+	}
+	switch {
+	case statefulSet == nil:
+		progressing.Status = corev1.ConditionTrue
+		progressing.Reason = reasonStatefulSetAbsent
+	case statefulSet.Status.ObservedGeneration < statefulSet.Generation ||
+		statefulSet.Status.CurrentRevision != statefulSet.Status.UpdateRevision ||
+		statefulSet.Status.UpdatedReplicas != replicas:
+		progressing.Status = corev1.ConditionTrue
+		progressing.Reason = reasonRollingUpdate
+		progressing.Message = fmt.Sprintf("%d of %d members are updated", statefulSet.Status.UpdatedReplicas, replicas)
+	case statefulSet.Status.Replicas != replicas || readyReplicas != replicas:
+		progressing.Status = corev1.ConditionTrue
+		progressing.Reason = reasonScaling
+		progressing.Message = fmt.Sprintf("%d of %d members are ready", readyReplicas, replicas)
+	}
+	newStatus.Conditions.SetCondition(progressing)
+
+	// 모든 멤버가 새로운 파드 템플릿으로 교체되었다면 스펙의 버전이 실행 중인 버전이 된다
+	if progressing.IsFalse() {
+		newStatus.Version = mysql.Spec.GetVersion()
+	}
+
+	// 데이터 볼륨이 스펙의 크기로 늘어났는지 확인한다. 볼륨은 멤버가 실행 중인 채로 늘어나므로 Ready 에는 영향을 주지 않는다
+	resizing := status.Condition{
+		Type:   mysqlv1alpha1.ConditionVolumeResizing,
+		Status: corev1.ConditionFalse,
+		Reason: reasonUpToDate,
+	}
+	size := getDataVolumeSize(mysql)
+	resized := 0
+	// 멤버의 수가 줄어들 때 남겨둔 볼륨 클레임은 늘리지 않으므로 현재 멤버의 볼륨 클레임만 센다
+	memberClaims := getMemberDataVolumeClaims(mysql, claims)
+	for i := range memberClaims {
+		if isDataVolumeClaimResized(&memberClaims[i], size) {
+			resized++
+		}
+	}
+	if resized < len(memberClaims) {
+		resizing.Status = corev1.ConditionTrue
+		resizing.Reason = reasonExpandingVolumes
+		resizing.Message = fmt.Sprintf("%d of %d data volume claims are resized to %s", resized, len(memberClaims), size.String())
+	} else if statefulSet != nil {
+		if current, ok := getStatefulSetDataVolumeSize(statefulSet); ok && current.Cmp(size) < 0 {
+			resizing.Status = corev1.ConditionTrue
+			resizing.Reason = reasonRecreating
+			resizing.Message = fmt.Sprintf("recreating stateful set to resize data volume claim template to %s", size.String())
+		}
+	}
+	newStatus.Conditions.SetCondition(resizing)
+
+	// 레플리카가 준비되었는지 확인한다. 레플리카는 프라이머리를 복제할 수 있어야 준비 상태가 된다
+	replication := status.Condition{
+		Type:   mysqlv1alpha1.ConditionReplicationHealthy,
+		Status: corev1.ConditionTrue,
+		Reason: reasonReplicasReady,
+	}
+	readyReplicaMembers := readyReplicas
+	if primaryReady {
+		readyReplicaMembers--
+	}
+	switch {
+	case replicas == 1:
+		replication.Reason = reasonNoReplicas
+	case !primaryReady:
+		replication.Status = corev1.ConditionFalse
+		replication.Reason = reasonPrimaryNotReady
+		replication.Message = fmt.Sprintf("primary %s is not ready", primaryName)
+	case readyReplicaMembers < replicas-1:
+		replication.Status = corev1.ConditionFalse
+		replication.Reason = reasonReplicasNotReady
+		replication.Message = fmt.Sprintf("%d of %d replicas are ready", readyReplicaMembers, replicas-1)
+	}
+	newStatus.Conditions.SetCondition(replication)
+
+	// 모든 멤버가 준비되었으면 Ready, 준비된 적이 있던 클러스터의 멤버가 준비되지 않았으면 Degraded 가 된다
+	ready := status.Condition{
+		Type:   mysqlv1alpha1.ConditionReady,
+		Status: corev1.ConditionTrue,
+		Reason: reasonAllMembersReady,
+	}
+	if progressing.IsTrue() || readyReplicas != replicas {
+		ready.Status = corev1.ConditionFalse
+		ready.Reason = reasonMembersNotReady
+		ready.Message = fmt.Sprintf("%d of %d members are ready", readyReplicas, replicas)
+	}
+	newStatus.Conditions.SetCondition(ready)
+
+	switch {
+	case ready.IsTrue():
+		newStatus.Phase = mysqlv1alpha1.MySQLPhaseReady
+	case newStatus.Phase == "" || newStatus.Phase == mysqlv1alpha1.MySQLPhaseCreating:
+		newStatus.Phase = mysqlv1alpha1.MySQLPhaseCreating
+	default:
+		newStatus.Phase = mysqlv1alpha1.MySQLPhaseDegraded
+	}
+}
+
+// isPodReady 는 파드가 준비(Ready) 상태인지 리턴한다
+func isPodReady(pod *corev1.Pod) bool {
+	if pod.DeletionTimestamp != nil {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
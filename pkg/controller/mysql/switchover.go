@@ -0,0 +1,249 @@
+package mysql
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// 프라이머리를 바꿀 수 없는 경우의 컨디션 원인(reason)
+const (
+	reasonTargetNotReplicating status.ConditionReason = "TargetNotReplicating"
+	reasonTargetNotReady       status.ConditionReason = "TargetNotReady"
+	reasonPrimaryFailedOver    status.ConditionReason = "PrimaryFailedOver"
+)
+
+const (
+	// switchoverTimeout 은 스위치오버 중에 target 이 이전 프라이머리의 마지막 위치까지 적용하기를 기다리는 최대 시간이다
+	switchoverTimeout = 60 * time.Second
+	// switchoverCheckInterval 은 target 이 이전 프라이머리를 따라잡았는지 다시 확인하기까지 기다리는 시간이다
+	switchoverCheckInterval = 2 * time.Second
+)
+
+// getSwitchoverTarget 은 프라이머리를 바꿀 멤버를 리턴한다. 바꿀 멤버가 없으면 빈 문자열을 리턴한다
+// 스펙이 자동 페일오버나 그룹의 선출로 물러난 이전 프라이머리를 가리키면, 이전 프라이머리가 돌아오자마자 쓰기를 다시 중단하고
+// 되돌아가지 않도록 페일오버 뒤에 스펙이 바뀔 때까지 무시한다. 이 경우는 observeSwitchover 가 컨디션으로 알린다
+func getSwitchoverTarget(mysql *mysqlv1alpha1.MySQL) string {
+	if isSwitchbackPending(mysql) {
+		return ""
+	}
+	return mysql.Spec.Primary
+}
+
+// isSwitchbackPending 은 스펙의 프라이머리가 페일오버로 물러난 이전 프라이머리를 가리키고, 페일오버 뒤에 스펙이 바뀌지 않았는지 리턴한다
+func isSwitchbackPending(mysql *mysqlv1alpha1.MySQL) bool {
+	last := mysql.Status.LastFailover
+	return last != nil && last.Reason != failoverReasonSwitchover && mysql.Spec.Primary == last.OldPrimary &&
+		mysql.Generation <= last.ObservedGeneration
+}
+
+// switchover 는 데이터 손실 없이 프라이머리를 target 멤버로 바꾼다
+// 이전 프라이머리의 쓰기를 막고 target 이 마지막 위치까지 적용하면 target 을 승격한 다음,
+// 이전 프라이머리와 같은 위치까지 적용한 레플리카가 target 을 복제하도록 바꾼다. 이미 복제가 깨진 레플리카는 바꾸지 않는다
+// target 이 따라잡기를 기다리는 동안에는 조정 루프를 붙잡지 않고 다시 예약하며, 시작한 시점은 switchoverStartedAt 에 기억한다
+// target 이 시간 안에 따라잡지 못하면 이전 프라이머리의 쓰기를 다시 허용하고 다음 조정 루프에서 다시 시도한다
+func (r *ReconcileMySQL) switchover(mysql *mysqlv1alpha1.MySQL, oldPrimary, target string, pods []corev1.Pod) (reconcile.Result, error) {
+	var replicas []string
+	targetReady := false
+	for i := range pods {
+		if pods[i].Name == oldPrimary || !isPodReady(&pods[i]) {
+			continue
+		}
+		replicas = append(replicas, pods[i].Name)
+		if pods[i].Name == target {
+			targetReady = true
+		}
+	}
+	started, inProgress := r.switchoverStartedAt.Load(mysql.UID)
+	if !targetReady {
+		if inProgress {
+			return reconcile.Result{}, r.abortSwitchover(mysql, oldPrimary, fmt.Errorf("%s is no longer ready", target))
+		}
+		klog.Infof("[%s] Waiting for %s to be ready to switch primary", mysql.Name, target)
+		return reconcile.Result{RequeueAfter: failoverRetryInterval}, nil
+	}
+	if !inProgress {
+		// 바꿀 수 없다면 다른 멤버의 조정을 막지 않도록 에러를 리턴하지 않는다. 이유는 observeSwitchover 가 컨디션으로 알리며,
+		// target 이 프라이머리를 복제하게 되면 주기적인 조정 루프에서 다시 시도한다
+		blocker, err := r.getSwitchoverBlocker(mysql, oldPrimary, target)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if blocker != "" {
+			klog.Errorf("[%s] %s", mysql.Name, blocker)
+			return reconcile.Result{}, nil
+		}
+		klog.Infof("[%s] Switch primary from %s to %s", mysql.Name, oldPrimary, target)
+		if err := r.setReadOnly(mysql, oldPrimary, true); err != nil {
+			return reconcile.Result{}, err
+		}
+		started = time.Now()
+		r.switchoverStartedAt.Store(mysql.UID, started)
+	}
+
+	// target 이 이전 프라이머리의 마지막 위치까지 적용하기를 기다린다. 다른 레플리카는 기다리지 않는다
+	file, filePosition, err := r.getBinlogPosition(mysql, oldPrimary)
+	if err != nil {
+		return reconcile.Result{}, r.abortSwitchover(mysql, oldPrimary, err)
+	}
+	position, err := r.getReplicaPosition(mysql, target)
+	if err != nil {
+		return reconcile.Result{}, r.abortSwitchover(mysql, oldPrimary, err)
+	}
+	if position.execFile != file || position.execPosition != filePosition {
+		if elapsed := time.Since(started.(time.Time)); elapsed < switchoverTimeout {
+			klog.Infof("[%s] Waiting for %s to catch up with primary %s (%s)", mysql.Name, target, oldPrimary, elapsed.Round(time.Second))
+			return reconcile.Result{RequeueAfter: switchoverCheckInterval}, nil
+		}
+		return reconcile.Result{}, r.abortSwitchover(mysql, oldPrimary,
+			fmt.Errorf("%s did not catch up with primary %s in %s", target, oldPrimary, switchoverTimeout))
+	}
+	r.switchoverStartedAt.Delete(mysql.UID)
+
+	klog.Infof("[%s] Promote %s to primary", mysql.Name, target)
+	newFile, newPosition, err := r.promote(mysql, target)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	password, err := r.getSystemUserPassword(mysql, replicationUser)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	// 이전 프라이머리도 target 과 같은 위치까지 가지고 있으므로 레플리카가 된다
+	// GTID 모드에서는 따라잡지 못한 레플리카도 빠진 트랜잭션을 target 에서 받으므로 모두 target 을 복제한다
+	gtid := mysql.Spec.GetReplicationMode() == mysqlv1alpha1.ReplicationModeGTID
+	primaryHost := getMemberHost(mysql, oldPrimary)
+	var stale []string
+	for _, name := range append(replicas, oldPrimary) {
+		if name == target {
+			continue
+		}
+		if name != oldPrimary {
+			caughtUp, broken := false, ""
+			if position, err := r.getReplicaPosition(mysql, name); err == nil {
+				caughtUp = position.execFile == file && position.execPosition == filePosition
+				broken = getReplicationFailure(position, primaryHost)
+			}
+			// 복제가 깨진 레플리카는 새로운 프라이머리를 복제하도록 바꿔도 해결되지 않으므로 ReplicationBroken 컨디션에 맡긴다
+			if broken != "" {
+				klog.Infof("[%s] Skip repointing %s: %s", mysql.Name, name, broken)
+				continue
+			}
+			if !gtid && !caughtUp {
+				stale = append(stale, name)
+				continue
+			}
+		}
+		klog.Infof("[%s] Repoint %s to new primary %s", mysql.Name, name, target)
+		if err := r.changePrimary(mysql, name, target, password, newFile, newPosition); err != nil {
+			klog.Errorf("[%s] Could not repoint %s to new primary: %v", mysql.Name, name, err)
+			stale = append(stale, name)
+		}
+	}
+
+	message := ""
+	if len(stale) > 0 {
+		message = fmt.Sprintf("%s could not be repointed to the new primary and must be re-cloned", strings.Join(stale, ", "))
+	}
+	return reconcile.Result{}, r.recordFailover(mysql, &mysqlv1alpha1.FailoverRecord{
+		Time:       metav1.Now(),
+		OldPrimary: oldPrimary,
+		NewPrimary: target,
+		Reason:     failoverReasonSwitchover,
+		Message:    message,
+	})
+}
+
+// getSwitchoverBlocker 는 target 으로 프라이머리를 바꿀 수 없는 이유를 리턴한다. 바꿀 수 있으면 빈 문자열을 리턴한다
+// target 이 현재 프라이머리를 복제하고 있어야 데이터 손실 없이 바꿀 수 있다
+func (r *ReconcileMySQL) getSwitchoverBlocker(mysql *mysqlv1alpha1.MySQL, oldPrimary, target string) (string, error) {
+	position, err := r.getReplicaPosition(mysql, target)
+	if _, ok := err.(*notReplicatingError); ok {
+		return fmt.Sprintf("cannot switch primary to %s: it is not replicating", target), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if position.primaryHost != getMemberHost(mysql, oldPrimary) || !position.ioRunning || !position.sqlRunning {
+		return fmt.Sprintf("cannot switch primary to %s: it is not replicating from primary %s", target, oldPrimary), nil
+	}
+	return "", nil
+}
+
+// observeSwitchover 는 스펙에 지정한 프라이머리로 바꾸지 못하고 있는 이유를 SwitchoverBlocked 컨디션에 기록한다
+// 프라이머리가 준비되지 않았다면 스위치오버 대신 페일오버를 기다리므로 컨디션을 바꾸지 않는다
+func (r *ReconcileMySQL) observeSwitchover(mysql *mysqlv1alpha1.MySQL, newStatus *mysqlv1alpha1.MySQLStatus, pods []corev1.Pod) {
+	primaryName := getPrimaryPodName(mysql)
+	target := mysql.Spec.Primary
+	if target == "" || target == primaryName {
+		newStatus.Conditions.RemoveCondition(mysqlv1alpha1.ConditionSwitchoverBlocked)
+		return
+	}
+	if isSwitchbackPending(mysql) {
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:   mysqlv1alpha1.ConditionSwitchoverBlocked,
+			Status: corev1.ConditionTrue,
+			Reason: reasonPrimaryFailedOver,
+			Message: fmt.Sprintf("%s was replaced by %s after a failure; update spec.primary to switch back",
+				target, mysql.Status.LastFailover.NewPrimary),
+		})
+		return
+	}
+	if mysql.Spec.GetTopology() == mysqlv1alpha1.TopologyGroupReplication {
+		newStatus.Conditions.RemoveCondition(mysqlv1alpha1.ConditionSwitchoverBlocked)
+		return
+	}
+	primaryReady, targetReady := false, false
+	for i := range pods {
+		switch {
+		case pods[i].Name == primaryName:
+			primaryReady = isPodReady(&pods[i])
+		case pods[i].Name == target:
+			targetReady = isPodReady(&pods[i])
+		}
+	}
+	if !primaryReady {
+		return
+	}
+	if !targetReady {
+		newStatus.Conditions.SetCondition(status.Condition{
+			Type:    mysqlv1alpha1.ConditionSwitchoverBlocked,
+			Status:  corev1.ConditionTrue,
+			Reason:  reasonTargetNotReady,
+			Message: fmt.Sprintf("waiting for %s to be ready to switch primary", target),
+		})
+		return
+	}
+	blocker, err := r.getSwitchoverBlocker(mysql, primaryName, target)
+	if err != nil {
+		klog.Errorf("[%s] Could not check switchover to %s: %v", mysql.Name, target, err)
+		return
+	}
+	if blocker == "" {
+		newStatus.Conditions.RemoveCondition(mysqlv1alpha1.ConditionSwitchoverBlocked)
+		return
+	}
+	newStatus.Conditions.SetCondition(status.Condition{
+		Type:    mysqlv1alpha1.ConditionSwitchoverBlocked,
+		Status:  corev1.ConditionTrue,
+		Reason:  reasonTargetNotReplicating,
+		Message: blocker,
+	})
+}
+
+// abortSwitchover 는 스위치오버를 중단하고 이전 프라이머리의 쓰기를 다시 허용한다
+func (r *ReconcileMySQL) abortSwitchover(mysql *mysqlv1alpha1.MySQL, oldPrimary string, cause error) error {
+	klog.Errorf("[%s] Abort switchover: %v", mysql.Name, cause)
+	r.switchoverStartedAt.Delete(mysql.UID)
+	if err := r.setReadOnly(mysql, oldPrimary, false); err != nil {
+		return err
+	}
+	return fmt.Errorf("switchover aborted: %v", cause)
+}
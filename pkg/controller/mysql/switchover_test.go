@@ -0,0 +1,136 @@
+package mysql
+
+import (
+	"testing"
+
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetSwitchoverTarget(t *testing.T) {
+	tests := []struct {
+		name       string
+		primary    string
+		generation int64
+		last       *mysqlv1alpha1.FailoverRecord
+		want       string
+	}{
+		{
+			name:       "no failover",
+			primary:    "mysql-1",
+			generation: 1,
+			want:       "mysql-1",
+		},
+		{
+			name:       "switchover to the spec",
+			primary:    "mysql-1",
+			generation: 2,
+			last:       &mysqlv1alpha1.FailoverRecord{OldPrimary: "mysql-0", NewPrimary: "mysql-1", Reason: failoverReasonSwitchover, ObservedGeneration: 2},
+			want:       "mysql-1",
+		},
+		{
+			// 페일오버로 물러난 프라이머리로 바로 되돌아가지 않는다
+			name:       "spec names the failed primary",
+			primary:    "mysql-0",
+			generation: 1,
+			last:       &mysqlv1alpha1.FailoverRecord{OldPrimary: "mysql-0", NewPrimary: "mysql-1", Reason: failoverReasonPrimaryFailure, ObservedGeneration: 1},
+			want:       "",
+		},
+		{
+			name:       "spec names the member retired by group election",
+			primary:    "mysql-0",
+			generation: 3,
+			last:       &mysqlv1alpha1.FailoverRecord{OldPrimary: "mysql-0", NewPrimary: "mysql-2", Reason: failoverReasonGroupElection, ObservedGeneration: 3},
+			want:       "",
+		},
+		{
+			// 페일오버 뒤에 스펙이 바뀌었다면 사용자가 되돌리기를 확인한 것이다
+			name:       "spec changed after the failover",
+			primary:    "mysql-0",
+			generation: 2,
+			last:       &mysqlv1alpha1.FailoverRecord{OldPrimary: "mysql-0", NewPrimary: "mysql-1", Reason: failoverReasonPrimaryFailure, ObservedGeneration: 1},
+			want:       "mysql-0",
+		},
+		{
+			name:       "spec names another member",
+			primary:    "mysql-2",
+			generation: 1,
+			last:       &mysqlv1alpha1.FailoverRecord{OldPrimary: "mysql-0", NewPrimary: "mysql-1", Reason: failoverReasonPrimaryFailure, ObservedGeneration: 1},
+			want:       "mysql-2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mysql := &mysqlv1alpha1.MySQL{
+				ObjectMeta: metav1.ObjectMeta{Name: "mysql", Generation: tt.generation},
+				Spec:       mysqlv1alpha1.MySQLSpec{Primary: tt.primary},
+				Status:     mysqlv1alpha1.MySQLStatus{LastFailover: tt.last},
+			}
+			if got := getSwitchoverTarget(mysql); got != tt.want {
+				t.Errorf("getSwitchoverTarget() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestObserveSwitchover(t *testing.T) {
+	tests := []struct {
+		name       string
+		primary    string
+		last       *mysqlv1alpha1.FailoverRecord
+		readyPods  map[string]bool
+		wantReason string
+	}{
+		{
+			name:      "no target",
+			readyPods: map[string]bool{"mysql-0": true, "mysql-1": true},
+		},
+		{
+			name:       "target is not ready",
+			primary:    "mysql-1",
+			readyPods:  map[string]bool{"mysql-0": true, "mysql-1": false},
+			wantReason: string(reasonTargetNotReady),
+		},
+		{
+			name:       "spec names the failed primary",
+			primary:    "mysql-0",
+			last:       &mysqlv1alpha1.FailoverRecord{OldPrimary: "mysql-0", NewPrimary: "mysql-1", Reason: failoverReasonPrimaryFailure, ObservedGeneration: 1},
+			readyPods:  map[string]bool{"mysql-0": true, "mysql-1": true},
+			wantReason: string(reasonPrimaryFailedOver),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mysql := &mysqlv1alpha1.MySQL{
+				ObjectMeta: metav1.ObjectMeta{Name: "mysql", Generation: 1},
+				Spec:       mysqlv1alpha1.MySQLSpec{Primary: tt.primary},
+				Status:     mysqlv1alpha1.MySQLStatus{CurrentPrimary: "mysql-0", LastFailover: tt.last},
+			}
+			if tt.last != nil {
+				mysql.Status.CurrentPrimary = tt.last.NewPrimary
+			}
+			var pods []corev1.Pod
+			for _, name := range []string{"mysql-0", "mysql-1"} {
+				ready := corev1.ConditionFalse
+				if tt.readyPods[name] {
+					ready = corev1.ConditionTrue
+				}
+				pods = append(pods, corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: name},
+					Status:     corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: ready}}},
+				})
+			}
+			r := &ReconcileMySQL{}
+			newStatus := mysql.Status.DeepCopy()
+			r.observeSwitchover(mysql, newStatus, pods)
+			condition := newStatus.Conditions.GetCondition(mysqlv1alpha1.ConditionSwitchoverBlocked)
+			switch {
+			case tt.wantReason == "" && condition != nil:
+				t.Errorf("SwitchoverBlocked = %v, want no condition", condition)
+			case tt.wantReason != "" && (condition == nil || string(condition.Reason) != tt.wantReason):
+				t.Errorf("SwitchoverBlocked = %v, want reason %s", condition, tt.wantReason)
+			}
+		})
+	}
+}
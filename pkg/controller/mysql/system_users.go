@@ -0,0 +1,236 @@
+package mysql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// usernameKey 는 시스템 계정의 시크릿에서 계정 이름을 가진 키이다
+const usernameKey = "username"
+
+// systemUsersRetryInterval 은 프라이머리에 시스템 계정을 만들지 못했을 때 다시 시도하기까지 기다리는 시간이다
+// 멤버의 준비 상태 확인이 모니터링 계정을 사용하므로 계정이 만들어지기 전까지는 파드의 변경으로 조정 루프에 진입하지 않는다
+const systemUsersRetryInterval = 10 * time.Second
+
+// systemUser 는 멤버 사이의 복제와 백업, 상태 확인에 root 대신 사용하는 최소 권한의 MySQL 계정이다
+type systemUser struct {
+	// name 은 MySQL 계정의 이름이다
+	name string
+	// secretSuffix 는 계정의 이름과 비밀번호를 가진 시크릿 이름의 접미사이다
+	secretSuffix string
+	// envPrefix 는 컨테이너에서 계정의 이름과 비밀번호를 가진 환경변수 이름의 접두사이다
+	envPrefix string
+}
+
+var (
+	replicationUser = systemUser{name: "operator_repl", secretSuffix: "replication", envPrefix: "REPLICATION"}
+	backupUser      = systemUser{name: "operator_backup", secretSuffix: "backup", envPrefix: "BACKUP"}
+	monitorUser     = systemUser{name: "operator_monitor", secretSuffix: "monitor", envPrefix: "MONITOR"}
+	systemUsers     = []systemUser{replicationUser, backupUser, monitorUser}
+)
+
+// getSystemUserGrants 는 시스템 계정에 부여하는 권한의 목록을 리턴한다
+func getSystemUserGrants(mysql *mysqlv1alpha1.MySQL, user systemUser) []string {
+	switch user {
+	case replicationUser:
+		grants := []string{"REPLICATION SLAVE ON *.*"}
+		// 그룹의 분산 복구는 필요한 바이너리 로그가 지워졌으면 clone 플러그인으로 데이터를 받으며, 데이터를 보내는 멤버에서 BACKUP_ADMIN 이 필요하다
+		if mysql.Spec.GetTopology() == mysqlv1alpha1.TopologyGroupReplication {
+			grants = append(grants, "BACKUP_ADMIN ON *.*")
+		}
+		return grants
+	case backupUser:
+		grants := []string{"RELOAD, LOCK TABLES, PROCESS, REPLICATION CLIENT ON *.*"}
+		// MySQL 8.0 의 xtrabackup 은 백업 잠금(LOCK INSTANCE FOR BACKUP)과 바이너리 로그 위치를 읽기 위한 권한이 더 필요하다
+		if mysql.Spec.GetVersion() == "8.0" {
+			grants = append(grants, "BACKUP_ADMIN ON *.*", "SELECT ON performance_schema.log_status")
+		}
+		return grants
+	case monitorUser:
+		return []string{"PROCESS, REPLICATION CLIENT ON *.*"}
+	}
+	return nil
+}
+
+// syncSystemUserSecrets 는 시스템 계정의 이름과 비밀번호를 가진 시크릿을 확인하고, 없으면 무작위 비밀번호로 생성한다
+// 비밀번호는 멤버의 계정에 기록되어 있으므로 이미 만들어진 시크릿은 바꾸지 않는다
+func (r *ReconcileMySQL) syncSystemUserSecrets(mysql *mysqlv1alpha1.MySQL) error {
+	klog.Infof("[%s] syncSystemUserSecrets", mysql.Name)
+	for _, user := range systemUsers {
+		secret := &corev1.Secret{}
+		if err := r.client.Get(context.TODO(), getSystemUserSecretName(mysql, user), secret); err != nil {
+			// Not Found 에러가 아닌 경우는 가져오는데 실패한 것이므로 에러를 바로 리턴한다
+			if !errors.IsNotFound(err) {
+				return err
+			}
+			klog.Infof("[%s] Could not find %s user secret. Create a new one", mysql.Name, user.secretSuffix)
+			if err := r.createSystemUserSecret(mysql, user); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, key := range []string{usernameKey, passwordKey} {
+			if _, ok := secret.Data[key]; !ok {
+				return fmt.Errorf("%s user secret %s has no key %s", user.secretSuffix, secret.Name, key)
+			}
+		}
+		if err := r.adoptCredentialSecret(mysql, secret); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getSystemUserPassword 는 시크릿에서 시스템 계정의 비밀번호를 읽는다
+func (r *ReconcileMySQL) getSystemUserPassword(mysql *mysqlv1alpha1.MySQL, user systemUser) (string, error) {
+	name := getSystemUserSecretName(mysql, user)
+	secret := &corev1.Secret{}
+	if err := r.client.Get(context.TODO(), name, secret); err != nil {
+		return "", err
+	}
+	password, ok := secret.Data[passwordKey]
+	if !ok {
+		return "", fmt.Errorf("%s user secret %s has no key %s", user.secretSuffix, name.Name, passwordKey)
+	}
+	return string(password), nil
+}
+
+// getSystemUserSecretName 는 시스템 계정의 시크릿의 이름과 네임스페이스를 리턴한다
+func getSystemUserSecretName(mysql *mysqlv1alpha1.MySQL, user systemUser) types.NamespacedName {
+	return types.NamespacedName{Namespace: mysql.Namespace, Name: mysql.Name + "-" + user.secretSuffix}
+}
+
+// createSystemUserSecret 는 무작위 비밀번호를 가진 시스템 계정의 시크릿을 생성한다. 이미 시크릿이 존재하는 경우 성공한다
+func (r *ReconcileMySQL) createSystemUserSecret(mysql *mysqlv1alpha1.MySQL, user systemUser) error {
+	secret, err := newSystemUserSecret(mysql, user, r.scheme)
+	if err != nil {
+		return err
+	}
+	if err := r.client.Create(context.TODO(), secret); err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// newSystemUserSecret 는 시스템 계정의 시크릿을 위한 객체를 생성한다. 객체는 mysql 객체를 오너로 가진다
+func newSystemUserSecret(mysql *mysqlv1alpha1.MySQL, user systemUser, scheme *runtime.Scheme) (*corev1.Secret, error) {
+	password, err := generatePassword()
+	if err != nil {
+		return nil, err
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: v1.ObjectMeta{
+			Name:        getSystemUserSecretName(mysql, user).Name,
+			Namespace:   getSystemUserSecretName(mysql, user).Namespace,
+			Labels:      labelsForMySQL(mysql, componentCredentials),
+			Annotations: annotationsForMySQL(mysql),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			usernameKey: []byte(user.name),
+			passwordKey: []byte(password),
+		},
+	}
+	if err := controllerutil.SetControllerReference(mysql, secret, scheme); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// newSystemUserEnv 는 시스템 계정들의 이름과 비밀번호를 시크릿에서 읽어오는 환경변수를 리턴한다
+// 스크립트와 프로브는 <envPrefix>_USER 와 <envPrefix>_PASSWORD 환경변수로 접속한다
+func newSystemUserEnv(mysql *mysqlv1alpha1.MySQL, users ...systemUser) []corev1.EnvVar {
+	var env []corev1.EnvVar
+	for _, user := range users {
+		name := getSystemUserSecretName(mysql, user).Name
+		env = append(env, corev1.EnvVar{
+			Name: user.envPrefix + "_USER",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: name},
+					Key:                  usernameKey,
+				},
+			},
+		}, corev1.EnvVar{
+			Name: user.envPrefix + "_PASSWORD",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: name},
+					Key:                  passwordKey,
+				},
+			},
+		})
+	}
+	return env
+}
+
+// syncSystemUsers 는 프라이머리에 시스템 계정을 만들고 비밀번호와 권한을 시크릿과 스펙에 맞춘다. 계정은 복제로 다른 멤버에 전달된다
+// 멤버의 준비 상태 확인이 모니터링 계정을 사용하므로 프라이머리가 준비되기 전이라도 mysqld 가 실행 중이면 계정을 만든다
+// 같은 계정을 매번 다시 만들어 바이너리 로그에 쓰지 않도록 마지막으로 적용한 내용을 기억하며, 오퍼레이터가 다시 시작되면 한 번 더 적용한다
+// 계정을 아직 만들 수 없으면 프라이머리의 장애 처리를 막지 않도록 에러 대신 true 를 리턴해서 다시 시도하게 한다
+func (r *ReconcileMySQL) syncSystemUsers(mysql *mysqlv1alpha1.MySQL) (bool, error) {
+	klog.Infof("[%s] syncSystemUsers", mysql.Name)
+	var queries []string
+	for _, user := range systemUsers {
+		password, err := r.getSystemUserPassword(mysql, user)
+		if err != nil {
+			return false, err
+		}
+		account := quoteSQL(user.name) + "@'%'"
+		queries = append(queries,
+			fmt.Sprintf("CREATE USER IF NOT EXISTS %s IDENTIFIED BY %s", account, quoteSQL(password)),
+			fmt.Sprintf("ALTER USER %s IDENTIFIED BY %s", account, quoteSQL(password)))
+		for _, grant := range getSystemUserGrants(mysql, user) {
+			queries = append(queries, fmt.Sprintf("GRANT %s TO %s", grant, account))
+		}
+	}
+	query := strings.Join(queries, "; ")
+	// 비밀번호를 메모리에 남기지 않도록 적용한 내용의 해시만 기억한다
+	sum := sha256.Sum256([]byte(query))
+	applied := hex.EncodeToString(sum[:])
+	if last, ok := r.appliedSystemUsers.Load(mysql.UID); ok && last == applied {
+		return false, nil
+	}
+
+	primaryName := getPrimaryPodName(mysql)
+	pod := &corev1.Pod{}
+	if err := r.client.Get(context.TODO(), types.NamespacedName{Namespace: mysql.Namespace, Name: primaryName}, pod); err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	if !isContainerRunning(pod, mysqlContainerName) {
+		return true, nil
+	}
+	if _, err := r.runSQL(mysql, primaryName, query); err != nil {
+		klog.Infof("[%s] Could not create system users on %s yet: %v", mysql.Name, primaryName, err)
+		return true, nil
+	}
+	klog.Infof("[%s] Created system users on %s", mysql.Name, primaryName)
+	r.appliedSystemUsers.Store(mysql.UID, applied)
+	return false, nil
+}
+
+// isContainerRunning 은 파드의 컨테이너가 실행 중인지 리턴한다
+func isContainerRunning(pod *corev1.Pod, name string) bool {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == name {
+			return status.State.Running != nil
+		}
+	}
+	return false
+}
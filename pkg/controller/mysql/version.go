@@ -0,0 +1,153 @@
+package mysql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+)
+
+// versionImages 는 MySQL 서버의 버전별로 사용할 이미지이다
+type versionImages struct {
+	// mysql 은 mysqld 를 실행하는 이미지이다
+	mysql string
+	// xtrabackup 은 멤버 사이에 데이터를 복제할 때 사용하는 백업 도구의 이미지이다
+	xtrabackup string
+}
+
+// supportedVersions 는 오퍼레이터가 지원하는 MySQL 서버의 버전과 그 버전에 사용할 이미지이다
+// xtrabackup 은 MySQL 서버의 메이저 버전에 맞는 버전을 사용해야 한다
+var supportedVersions = map[string]versionImages{
+	"5.7": {
+		mysql:      "mysql:5.7",
+		xtrabackup: "gcr.io/google-samples/xtrabackup:1.0",
+	},
+	"8.0": {
+		mysql:      "mysql:8.0",
+		xtrabackup: "percona/percona-xtrabackup:8.0",
+	},
+}
+
+// 스펙이 잘못된 경우의 컨디션 원인(reason)
+const (
+	reasonUnsupportedVersion status.ConditionReason = "UnsupportedVersion"
+	reasonInvalidPrimary     status.ConditionReason = "InvalidPrimary"
+	reasonReplicationMode    status.ConditionReason = "ReplicationModeChanged"
+)
+
+// specError 는 스펙이 잘못되어서 다시 시도해도 해결되지 않는 에러이다
+// 조정 루프는 이 에러를 상태에 기록하고 스펙이 바뀔 때까지 다시 시도하지 않는다
+type specError struct {
+	reason  status.ConditionReason
+	message string
+}
+
+func (e *specError) Error() string {
+	return e.message
+}
+
+// validateSpec 은 오퍼레이터가 반영할 수 없는 스펙인지 확인한다
+func validateSpec(mysql *mysqlv1alpha1.MySQL) error {
+	version := mysql.Spec.GetVersion()
+	if _, ok := supportedVersions[version]; !ok {
+		return &specError{reason: reasonUnsupportedVersion, message: fmt.Sprintf("unsupported mysql version %q", version)}
+	}
+	// MySQL 은 데이터 디렉터리를 이전 메이저 버전으로 되돌리는 것을 지원하지 않는다
+	if mysql.Status.Version != "" && compareVersions(version, mysql.Status.Version) < 0 {
+		return &specError{reason: reasonUnsupportedVersion,
+			message: fmt.Sprintf("downgrading mysql from %s to %s is not supported", mysql.Status.Version, version)}
+	}
+	// 복제 방법을 바꾸려면 모든 멤버의 gtid_mode 를 단계적으로 바꿔야 하므로 지원하지 않는다
+	if mode := mysql.Spec.GetReplicationMode(); mysql.Status.ReplicationMode != "" && mode != mysql.Status.ReplicationMode {
+		return &specError{reason: reasonReplicationMode,
+			message: fmt.Sprintf("changing replication mode from %s to %s is not supported", mysql.Status.ReplicationMode, mode)}
+	}
+	if err := validateDataVolumeClaim(mysql); err != nil {
+		return err
+	}
+	if err := validateTopology(mysql); err != nil {
+		return err
+	}
+	if err := validateSemiSync(mysql); err != nil {
+		return err
+	}
+	// 프라이머리는 멤버 중 하나여야 한다
+	if primary := mysql.Spec.Primary; primary != "" {
+		found := false
+		for i := 0; i < int(mysql.Spec.GetReplicas()); i++ {
+			if getPodName(mysql, i) == primary {
+				found = true
+			}
+		}
+		if !found {
+			return &specError{reason: reasonInvalidPrimary, message: fmt.Sprintf("primary %q is not a member", primary)}
+		}
+	}
+	return nil
+}
+
+// compareVersions 는 major.minor 형식의 두 버전을 숫자로 비교해서 a 가 b 보다 낮으면 -1, 같으면 0, 높으면 1 을 리턴한다
+// 문자열로 비교하면 5.10 이 5.7 보다 낮게 정렬되므로 각 부분을 정수로 바꿔서 비교한다. 형식이 맞지 않는 버전은 같은 것으로 본다
+func compareVersions(a, b string) int {
+	aMajor, aMinor, aOK := parseVersion(a)
+	bMajor, bMinor, bOK := parseVersion(b)
+	switch {
+	case !aOK || !bOK:
+		return 0
+	case aMajor != bMajor:
+		if aMajor < bMajor {
+			return -1
+		}
+		return 1
+	case aMinor != bMinor:
+		if aMinor < bMinor {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// parseVersion 은 major.minor 형식의 버전을 메이저와 마이너 버전으로 나눈다
+func parseVersion(version string) (int, int, bool) {
+	parts := strings.Split(version, ".")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
+
+// getPrimaryAuthOptions 는 레플리카가 프라이머리에 접속하도록 바꿀 때 CHANGE MASTER TO 에 더하는 인증 옵션을 리턴한다
+// MySQL 8.0 의 기본 인증 방식(caching_sha2_password)은 암호화되지 않은 연결에서는 프라이머리의 공개키를 받아야 비밀번호를 보낼 수 있다
+func getPrimaryAuthOptions(mysql *mysqlv1alpha1.MySQL) string {
+	if mysql.Spec.GetVersion() == "8.0" {
+		return ", GET_MASTER_PUBLIC_KEY=1"
+	}
+	return ""
+}
+
+// getMySQLImage 는 mysqld 컨테이너의 이미지를 리턴한다
+func getMySQLImage(mysql *mysqlv1alpha1.MySQL) string {
+	if mysql.Spec.Image != "" {
+		return mysql.Spec.Image
+	}
+	return supportedVersions[mysql.Spec.GetVersion()].mysql
+}
+
+// getBackupImage 는 xtrabackup 사이드카와 clone-mysql 초기화 컨테이너의 이미지를 리턴한다
+func getBackupImage(mysql *mysqlv1alpha1.MySQL) string {
+	if mysql.Spec.BackupSidecar != nil && mysql.Spec.BackupSidecar.Image != "" {
+		return mysql.Spec.BackupSidecar.Image
+	}
+	return supportedVersions[mysql.Spec.GetVersion()].xtrabackup
+}
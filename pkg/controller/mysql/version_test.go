@@ -0,0 +1,23 @@
+package mysql
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "5.7", b: "5.7", want: 0},
+		{a: "5.7", b: "8.0", want: -1},
+		{a: "8.0", b: "5.7", want: 1},
+		{a: "5.10", b: "5.7", want: 1},
+		{a: "5.7", b: "5.10", want: -1},
+		{a: "10.0", b: "8.0", want: 1},
+		{a: "latest", b: "5.7", want: 0},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/operator-framework/operator-sdk/pkg/status"
+	mysqlv1alpha1 "github.com/woohhan/sample-mysql-operator/pkg/apis/mysql/v1alpha1"
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// 데이터 볼륨의 크기를 바꿀 수 없는 경우의 컨디션 원인(reason)
+const (
+	reasonVolumeNotExpandable status.ConditionReason = "VolumeNotExpandable"
+	reasonVolumeShrink        status.ConditionReason = "VolumeShrinkUnsupported"
+	reasonVolumeClaimTemplate status.ConditionReason = "VolumeClaimTemplateChanged"
+)
+
+// syncDataVolumeSize 는 스펙의 데이터 볼륨 크기가 커졌을 때 각 멤버의 볼륨 클레임을 늘린다
+// 볼륨 클레임 템플릿은 바꿀 수 없는 필드이므로 모든 볼륨 클레임이 늘어나면 스테이트풀셋을 파드는 남겨둔 채(orphan) 삭제하고,
+// 다음 조정 루프에서 새로운 템플릿으로 다시 만든다. 새 스테이트풀셋은 남겨둔 파드를 그대로 이어받으므로 멤버는 재시작하지 않는다
+// 스테이트풀셋을 삭제했다면 true 를 리턴한다
+func (r *ReconcileMySQL) syncDataVolumeSize(mysql *mysqlv1alpha1.MySQL, statefulSet *v1.StatefulSet) (bool, error) {
+	size := getDataVolumeSize(mysql)
+	current, ok := getStatefulSetDataVolumeSize(statefulSet)
+	if !ok {
+		return false, nil
+	}
+	// 볼륨은 늘릴 수만 있고 줄일 수는 없다
+	if size.Cmp(current) < 0 {
+		return false, &specError{reason: reasonVolumeShrink,
+			message: fmt.Sprintf("shrinking data volumes from %s to %s is not supported", current.String(), size.String())}
+	}
+
+	// 볼륨 클레임을 늘리는 중에 추가된 멤버는 이전 템플릿의 크기로 만들어지므로 템플릿과 관계없이 모든 멤버의 볼륨 클레임을 확인한다
+	claims, err := r.listDataVolumeClaims(mysql)
+	if err != nil {
+		return false, err
+	}
+	claims = getMemberDataVolumeClaims(mysql, claims)
+	resized := 0
+	for i := range claims {
+		claim := &claims[i]
+		request := claim.Spec.Resources.Requests[corev1.ResourceStorage]
+		if request.Cmp(size) < 0 {
+			if err := r.expandDataVolumeClaim(mysql, claim, size); err != nil {
+				return false, err
+			}
+			continue
+		}
+		if isDataVolumeClaimResized(claim, size) {
+			resized++
+		}
+	}
+	// 볼륨 클레임의 용량이 늘어나면 볼륨 클레임이 바뀌므로 다시 조정 루프에 진입한다
+	if resized < len(claims) {
+		klog.Infof("[%s] Waiting for data volume claims to be resized (%d/%d)", mysql.Name, resized, len(claims))
+		return false, nil
+	}
+	if size.Cmp(current) == 0 {
+		return false, nil
+	}
+
+	klog.Infof("[%s] Recreate mysql stateful set to resize data volume claim template to %s", mysql.Name, size.String())
+	if err := r.client.Delete(context.TODO(), statefulSet,
+		client.PropagationPolicy(metav1.DeletePropagationOrphan)); err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	return true, nil
+}
+
+// expandDataVolumeClaim 는 볼륨 클레임이 요청하는 크기를 size 로 늘린다
+// 스토리지 클래스가 볼륨 확장을 허용하지 않으면 API 서버가 거부하며, 이는 다시 시도해도 해결되지 않으므로 스펙 에러로 리턴한다
+func (r *ReconcileMySQL) expandDataVolumeClaim(mysql *mysqlv1alpha1.MySQL, claim *corev1.PersistentVolumeClaim, size resource.Quantity) error {
+	klog.Infof("[%s] Expand data volume claim %s to %s", mysql.Name, claim.Name, size.String())
+	if claim.Spec.Resources.Requests == nil {
+		claim.Spec.Resources.Requests = corev1.ResourceList{}
+	}
+	claim.Spec.Resources.Requests[corev1.ResourceStorage] = size
+	if err := r.client.Update(context.TODO(), claim); err != nil {
+		if errors.IsForbidden(err) || errors.IsInvalid(err) {
+			return &specError{reason: reasonVolumeNotExpandable,
+				message: fmt.Sprintf("data volume claim %s cannot be expanded: %v", claim.Name, err)}
+		}
+		return err
+	}
+	return nil
+}
+
+// getMemberDataVolumeClaims 는 볼륨 클레임 중 현재 멤버의 것만 리턴한다
+// 멤버의 수가 줄어들 때 남겨둔 볼륨 클레임은 마운트하는 파드가 없어서 노드에서 파일 시스템을 늘려야 하는 볼륨은 늘어나지 않는다
+// 이 볼륨 클레임은 멤버의 수가 다시 늘어나서 멤버의 것이 되면 늘린다
+func getMemberDataVolumeClaims(mysql *mysqlv1alpha1.MySQL, claims []corev1.PersistentVolumeClaim) []corev1.PersistentVolumeClaim {
+	var members []corev1.PersistentVolumeClaim
+	for i := range claims {
+		if ordinal, ok := getDataVolumeClaimOrdinal(mysql, &claims[i]); ok && ordinal < mysql.Spec.GetReplicas() {
+			members = append(members, claims[i])
+		}
+	}
+	return members
+}
+
+// isDataVolumeClaimResized 는 볼륨 클레임의 실제 용량이 size 이상인지 리턴한다
+func isDataVolumeClaimResized(claim *corev1.PersistentVolumeClaim, size resource.Quantity) bool {
+	capacity, ok := claim.Status.Capacity[corev1.ResourceStorage]
+	return ok && capacity.Cmp(size) >= 0
+}
+
+// getDataVolumeClaimTemplateStatus 는 스펙으로 만드는 데이터 볼륨 클레임 템플릿에서 크기 외에 바꿀 수 없는 설정을 리턴한다
+func getDataVolumeClaimTemplateStatus(mysql *mysqlv1alpha1.MySQL) *mysqlv1alpha1.DataVolumeClaimTemplateStatus {
+	claim := newDataVolumeClaim(mysql)
+	template := &mysqlv1alpha1.DataVolumeClaimTemplateStatus{
+		StorageClassName: claim.Spec.StorageClassName,
+		AccessModes:      claim.Spec.AccessModes,
+		Labels:           claim.Labels,
+		Annotations:      claim.Annotations,
+	}
+	// 스펙의 맵과 슬라이스를 상태와 공유하지 않도록 복사한다
+	return template.DeepCopy()
+}
+
+// validateDataVolumeClaim 은 클러스터를 만든 뒤에 데이터 볼륨 클레임의 설정이 바뀌지 않았는지 확인한다
+// 볼륨 클레임 템플릿은 바꿀 수 없고 이미 만들어진 볼륨 클레임에도 반영되지 않으므로, 크기 외의 변경은 반영하지 않고 알린다
+func validateDataVolumeClaim(mysql *mysqlv1alpha1.MySQL) error {
+	recorded := mysql.Status.DataVolumeClaimTemplate
+	if recorded == nil {
+		return nil
+	}
+	desired := getDataVolumeClaimTemplateStatus(mysql)
+	var changed []string
+	if !equality.Semantic.DeepEqual(recorded.StorageClassName, desired.StorageClassName) {
+		changed = append(changed, "storage class")
+	}
+	if !equality.Semantic.DeepEqual(recorded.AccessModes, desired.AccessModes) {
+		changed = append(changed, "access modes")
+	}
+	if !equality.Semantic.DeepEqual(recorded.Labels, desired.Labels) {
+		changed = append(changed, "labels")
+	}
+	if !equality.Semantic.DeepEqual(recorded.Annotations, desired.Annotations) {
+		changed = append(changed, "annotations")
+	}
+	if len(changed) > 0 {
+		return &specError{reason: reasonVolumeClaimTemplate,
+			message: fmt.Sprintf("changing %s of data volume claims is not supported", strings.Join(changed, ", "))}
+	}
+	return nil
+}
+
+// getDataVolumeSize 는 스펙이 요청하는 데이터 볼륨의 크기를 리턴한다
+func getDataVolumeSize(mysql *mysqlv1alpha1.MySQL) resource.Quantity {
+	if storage := mysql.Spec.Storage; storage != nil && storage.Size != nil {
+		return *storage.Size
+	}
+	return resource.MustParse(defaultDataVolumeSize)
+}
+
+// getStatefulSetDataVolumeSize 는 스테이트풀셋의 데이터 볼륨 클레임 템플릿이 요청하는 크기를 리턴한다
+func getStatefulSetDataVolumeSize(statefulSet *v1.StatefulSet) (resource.Quantity, bool) {
+	for _, template := range statefulSet.Spec.VolumeClaimTemplates {
+		if template.Name == dataVolumeName {
+			size, ok := template.Spec.Resources.Requests[corev1.ResourceStorage]
+			return size, ok
+		}
+	}
+	return resource.Quantity{}, false
+}
+
+// requestsForDataVolumeClaim 는 볼륨 클레임의 레이블로 볼륨 클레임을 가진 MySQL 객체의 조정 요청을 만든다
+// 스테이트풀셋은 셀렉터의 레이블을 볼륨 클레임에 붙이므로 인스턴스 레이블이 MySQL 객체의 이름이다
+func requestsForDataVolumeClaim(object handler.MapObject) []reconcile.Request {
+	labels := object.Meta.GetLabels()
+	if labels[labelName] != appName || labels[labelInstance] == "" {
+		return nil
+	}
+	return []reconcile.Request{{
+		NamespacedName: types.NamespacedName{Namespace: object.Meta.GetNamespace(), Name: labels[labelInstance]},
+	}}
+}